@@ -0,0 +1,370 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// Slice fields with the tag `gorm:"serializer:spanner_array"` are stored in
+// ARRAY columns. The serializer binds the slice as a single ARRAY parameter,
+// and converts the slice of spanner.Null* values that is returned by the
+// Cloud Spanner database/sql driver to the type of the field. The element
+// type of the column is derived from the element type of the slice, and the
+// size of the field is used as the length of STRING and BYTES elements. A
+// field can also specify the full column type, e.g.
+// `gorm:"serializer:spanner_array;type:ARRAY<STRING(100)>"`.
+//
+// Example:
+//
+//	type Singer struct {
+//		ID     int64
+//		Genres []string            `gorm:"serializer:spanner_array;size:50"`
+//		Scores []spanner.NullInt64 `gorm:"serializer:spanner_array"`
+//	}
+
+// arraySerializerName is the name of the serializer for slice fields that are
+// stored in ARRAY columns.
+const arraySerializerName = "spanner_array"
+
+// arrayElementDataTypes contains the data types of array elements that are not
+// derived from the kind of the element type.
+var arrayElementDataTypes = map[reflect.Type]schema.DataType{
+	reflect.TypeOf(spanner.NullString{}):  schema.String,
+	reflect.TypeOf(spanner.NullInt64{}):   schema.Int,
+	reflect.TypeOf(spanner.NullFloat64{}): schema.Float,
+	reflect.TypeOf(spanner.NullBool{}):    schema.Bool,
+	reflect.TypeOf(spanner.NullTime{}):    schema.Time,
+	reflect.TypeOf(time.Time{}):           schema.Time,
+	reflect.TypeOf(spanner.NullDate{}):    "DATE",
+	reflect.TypeOf(civil.Date{}):          "DATE",
+	reflect.TypeOf(spanner.NullNumeric{}): "NUMERIC",
+	reflect.TypeOf(big.Rat{}):             "NUMERIC",
+	reflect.TypeOf(spanner.NullJSON{}):    "JSON",
+}
+
+// isSliceField returns whether the field is a slice that is not stored in a
+// BYTES column.
+func isSliceField(field *schema.Field) bool {
+	return field.IndirectFieldType != nil && field.IndirectFieldType.Kind() == reflect.Slice && field.IndirectFieldType.Elem().Kind() != reflect.Uint8
+}
+
+// arrayElementDataType returns the gorm data type of the elements of an array
+// field.
+func arrayElementDataType(field *schema.Field) (schema.DataType, bool) {
+	t := field.IndirectFieldType.Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if dataType, ok := arrayElementDataTypes[t]; ok {
+		return dataType, true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return schema.Bool, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schema.Int, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema.Uint, true
+	case reflect.Float32, reflect.Float64:
+		return schema.Float, true
+	case reflect.String:
+		return schema.String, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return schema.Bytes, true
+		}
+	}
+	return "", false
+}
+
+// arrayDataType returns the ARRAY column type of an array field, or ARRAY if
+// the element type is not supported.
+func (dialector Dialector) arrayDataType(field *schema.Field) string {
+	dataType, ok := arrayElementDataType(field)
+	if !ok {
+		return "ARRAY"
+	}
+	return "ARRAY<" + dialector.DataTypeOf(&schema.Field{DataType: dataType, Size: field.Size}) + ">"
+}
+
+// arraySerializer binds a slice field as an ARRAY parameter, and sets the
+// field to the ARRAY value that is read from the database.
+type arraySerializer struct{}
+
+// Scan implements schema.SerializerInterface.
+func (arraySerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	value, err := convertArray(dbValue, field.IndirectFieldType)
+	if err != nil {
+		return fmt.Errorf("spanner: cannot scan ARRAY into field %s: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, value)
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (arraySerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	// The Cloud Spanner database/sql driver does not support pointers to
+	// slices.
+	if rv := reflect.ValueOf(fieldValue); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			fieldValue = reflect.Zero(field.IndirectFieldType).Interface()
+		} else {
+			fieldValue = rv.Elem().Interface()
+		}
+	}
+	value, err := driverArray(fieldValue)
+	if err != nil {
+		return nil, fmt.Errorf("spanner: cannot bind field %s: %w", field.Name, err)
+	}
+	return value, nil
+}
+
+// driverArrayElementTypes contains the element types of the slices that the
+// Cloud Spanner database/sql driver can bind for each kind of element.
+var driverArrayElementTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.Int:     reflect.TypeOf(int64(0)),
+	reflect.Int8:    reflect.TypeOf(int64(0)),
+	reflect.Int16:   reflect.TypeOf(int64(0)),
+	reflect.Int32:   reflect.TypeOf(int64(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(int64(0)),
+	reflect.Uint8:   reflect.TypeOf(int64(0)),
+	reflect.Uint16:  reflect.TypeOf(int64(0)),
+	reflect.Uint32:  reflect.TypeOf(int64(0)),
+	reflect.Uint64:  reflect.TypeOf(int64(0)),
+	reflect.Float32: reflect.TypeOf(float64(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// driverArray converts a slice to a slice type that the Cloud Spanner
+// database/sql driver can bind. The driver only supports slices of bool,
+// int64, float64 and string values and pointers to these, and not for example
+// []int32, []uint64, []float32 or slices of named string types. Other slices
+// are returned unchanged.
+func driverArray(value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice {
+		return value, nil
+	}
+	elemType := rv.Type().Elem()
+	if elemType.Kind() == reflect.Uint8 {
+		// []byte is bound as BYTES.
+		return value, nil
+	}
+	ptr := elemType.Kind() == reflect.Ptr
+	if ptr {
+		elemType = elemType.Elem()
+	}
+	target, ok := driverArrayElementTypes[elemType.Kind()]
+	if !ok || elemType == target || elemType.Implements(valuerType) || reflect.PtrTo(elemType).Implements(valuerType) {
+		return value, nil
+	}
+	resultElemType := target
+	if ptr {
+		resultElemType = reflect.PtrTo(target)
+	}
+	if rv.IsNil() {
+		return reflect.Zero(reflect.SliceOf(resultElemType)).Interface(), nil
+	}
+	result := reflect.MakeSlice(reflect.SliceOf(resultElemType), rv.Len(), rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if ptr {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+		if k := elem.Kind(); k >= reflect.Uint && k <= reflect.Uint64 && elem.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d is out of range for an INT64 column", elem.Uint())
+		}
+		converted := elem.Convert(target)
+		if ptr {
+			p := reflect.New(target)
+			p.Elem().Set(converted)
+			converted = p
+		}
+		result.Index(i).Set(converted)
+	}
+	return result.Interface(), nil
+}
+
+// arrayValue binds a slice as an ARRAY parameter. gorm would otherwise expand
+// a slice into a list of parameters.
+type arrayValue struct {
+	value interface{}
+}
+
+// Value implements driver.Valuer.
+func (a arrayValue) Value() (driver.Value, error) {
+	return driverArray(a.value)
+}
+
+// convertArray converts an ARRAY value that is returned by the Cloud Spanner
+// database/sql driver to a slice of type t. NULL elements are converted to
+// nil for slices of pointers, and to the zero value for all other slices.
+func convertArray(src interface{}, t reflect.Type) (interface{}, error) {
+	if src == nil {
+		return reflect.Zero(t).Interface(), nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type() == t {
+		return src, nil
+	}
+	if sv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("unsupported value type %T", src)
+	}
+	if sv.IsNil() {
+		return reflect.Zero(t).Interface(), nil
+	}
+	elemType := t.Elem()
+	result := reflect.MakeSlice(t, sv.Len(), sv.Len())
+	for i := 0; i < sv.Len(); i++ {
+		elem := sv.Index(i)
+		if elem.Type().AssignableTo(elemType) {
+			result.Index(i).Set(elem)
+			continue
+		}
+		value, valid := nullValue(elem.Interface())
+		if !valid {
+			continue
+		}
+		baseType := elemType
+		if elemType.Kind() == reflect.Ptr {
+			baseType = elemType.Elem()
+		}
+		rv := reflect.ValueOf(value)
+		if !rv.Type().ConvertibleTo(baseType) {
+			return nil, fmt.Errorf("cannot convert %T to %v", value, baseType)
+		}
+		rv = rv.Convert(baseType)
+		if elemType.Kind() == reflect.Ptr {
+			p := reflect.New(baseType)
+			p.Elem().Set(rv)
+			rv = p
+		}
+		result.Index(i).Set(rv)
+	}
+	return result.Interface(), nil
+}
+
+// nullValue returns the value of a spanner.Null* value, and whether the value
+// is not NULL.
+func nullValue(v interface{}) (interface{}, bool) {
+	switch n := v.(type) {
+	case spanner.NullString:
+		return n.StringVal, n.Valid
+	case spanner.NullInt64:
+		return n.Int64, n.Valid
+	case spanner.NullFloat64:
+		return n.Float64, n.Valid
+	case spanner.NullBool:
+		return n.Bool, n.Valid
+	case spanner.NullTime:
+		return n.Time, n.Valid
+	case spanner.NullDate:
+		return n.Date, n.Valid
+	case spanner.NullNumeric:
+		return n.Numeric, n.Valid
+	case []byte:
+		return n, n != nil
+	}
+	return v, v != nil
+}
+
+// arrayLiteral returns the ARRAY literal for the given value if it is an
+// array value.
+func arrayLiteral(v interface{}) (string, bool) {
+	a, ok := v.(arrayValue)
+	if !ok {
+		return "", false
+	}
+	rv := reflect.ValueOf(a.value)
+	if !rv.IsValid() || rv.Kind() == reflect.Slice && rv.IsNil() {
+		return "NULL", true
+	}
+	if rv.Kind() != reflect.Slice {
+		return "", false
+	}
+	elements := make([]string, rv.Len())
+	for i := range elements {
+		elem := rv.Index(i).Interface()
+		if literal, ok := valueLiteral(elem); ok {
+			elements[i] = literal
+		} else {
+			elements[i] = logger.ExplainSQL("?", nil, `'`, elem)
+		}
+	}
+	return "[" + strings.Join(elements, ",") + "]", true
+}
+
+// Array returns a value that is bound as an ARRAY parameter. gorm expands a
+// slice that is passed as a parameter to a list of parameters, and slices that
+// should be passed as a single ARRAY parameter must therefore be wrapped with
+// Array.
+//
+// Example:
+//
+//	db.Where("genres = ?", spannergorm.Array([]string{"pop", "rock"})).Find(&singers)
+func Array(value interface{}) driver.Valuer {
+	return arrayValue{value: value}
+}
+
+// ArrayIncludes returns a condition that checks whether the array in the
+// given column contains value.
+//
+// Example:
+//
+//	db.Where(spannergorm.ArrayIncludes("genres", "pop")).Find(&singers)
+func ArrayIncludes(column string, value interface{}) clause.Expression {
+	return clause.Expr{SQL: "ARRAY_INCLUDES(?, ?)", Vars: []interface{}{clause.Column{Name: column}, value}}
+}
+
+// ArrayLength returns an ARRAY_LENGTH expression for the array in the given
+// column. The expression can be used as the column of a gorm condition.
+//
+// Example:
+//
+//	db.Where(clause.Gt{Column: spannergorm.ArrayLength("genres"), Value: 1}).Find(&singers)
+func ArrayLength(column string) clause.Expr {
+	return clause.Expr{SQL: "ARRAY_LENGTH(?)", Vars: []interface{}{clause.Column{Name: column}}}
+}
+
+// InUnnest returns a condition that checks whether the value of the given
+// column is one of the elements of values. values is bound as a single ARRAY
+// parameter, which means that the SQL string of the statement is the same for
+// any number of values.
+//
+// Example:
+//
+//	db.Where(spannergorm.InUnnest("id", []int64{1, 2, 3})).Find(&singers)
+func InUnnest(column string, values interface{}) clause.Expression {
+	return clause.Expr{SQL: "? IN UNNEST(?)", Vars: []interface{}{clause.Column{Name: column}, arrayValue{value: values}}}
+}
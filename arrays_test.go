@@ -0,0 +1,184 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+type playlist struct {
+	ID      int64                `gorm:"primarykey;autoIncrement:false"`
+	Genres  []string             `gorm:"serializer:spanner_array;size:50"`
+	TrackID []int64              `gorm:"serializer:spanner_array"`
+	Notes   []spanner.NullString `gorm:"serializer:spanner_array"`
+	Ratings []*float64           `gorm:"serializer:spanner_array;type:array"`
+	Public  []bool               `gorm:"serializer:spanner_array;type:ARRAY<BOOL>"`
+}
+
+func TestArrayFields(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	p := playlist{
+		ID:     1,
+		Genres: []string{"pop", "rock"},
+		Notes:  []spanner.NullString{{StringVal: "new", Valid: true}, {}},
+	}
+	res := db.Session(&gorm.Session{DryRun: true}).Create(&p)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if g, w := db.Dialector.Explain(res.Statement.SQL.String(), res.Statement.Vars...),
+		"INSERT INTO `playlists` (`id`,`genres`,`track_id`,`notes`,`ratings`,`public`) VALUES (1,['pop','rock'],NULL,['new',NULL],NULL,NULL)"; g != w {
+		t.Fatalf("explain mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	field := res.Statement.Schema.LookUpField("Ratings")
+	value := field.NewValuePool.Get()
+	if err := value.(sql.Scanner).Scan([]spanner.NullFloat64{{Float64: 4.5, Valid: true}, {}}); err != nil {
+		t.Fatal(err)
+	}
+	var scanned playlist
+	if err := field.Set(context.Background(), reflect.ValueOf(&scanned).Elem(), value); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(scanned.Ratings), 2; g != w {
+		t.Fatalf("ratings length mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := *scanned.Ratings[0], 4.5; g != w {
+		t.Fatalf("rating mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if scanned.Ratings[1] != nil {
+		t.Fatalf("rating mismatch\n Got: %v\nWant: %v", scanned.Ratings[1], nil)
+	}
+}
+
+func TestArrayConditions(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	res := db.Session(&gorm.Session{DryRun: true}).
+		Where(InUnnest("id", []int64{1, 2, 3})).
+		Where(ArrayIncludes("genres", "pop")).
+		Where(clause.Gt{Column: ArrayLength("track_id"), Value: 10}).
+		Where("genres = ?", Array([]string{"pop"})).
+		Find(&[]playlist{})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if g, w := res.Statement.SQL.String(),
+		"SELECT * FROM `playlists` WHERE `id` IN UNNEST(?) AND ARRAY_INCLUDES(`genres`, ?) AND ARRAY_LENGTH(`track_id`) > ? AND genres = ?"; g != w {
+		t.Fatalf("sql mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := len(res.Statement.Vars), 4; g != w {
+		t.Fatalf("var count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := db.Dialector.Explain(res.Statement.SQL.String(), res.Statement.Vars...),
+		"SELECT * FROM `playlists` WHERE `id` IN UNNEST([1,2,3]) AND ARRAY_INCLUDES(`genres`, 'pop') AND ARRAY_LENGTH(`track_id`) > 10 AND genres = ['pop']"; g != w {
+		t.Fatalf("explain mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+type scoreCard struct {
+	ID      int64     `gorm:"primarykey;autoIncrement:false"`
+	Scores  []int32   `gorm:"serializer:spanner_array"`
+	Weights []float32 `gorm:"serializer:spanner_array"`
+	Bonus   []*uint16 `gorm:"serializer:spanner_array"`
+}
+
+func TestArrayFieldsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(
+		"INSERT INTO `score_cards` (`id`,`scores`,`weights`,`bonus`) VALUES (@p1,@p2,@p3,@p4)",
+		&testutil.StatementResult{Type: testutil.StatementResultUpdateCount, UpdateCount: 1},
+	)
+	bonus := uint16(5)
+	if err := db.Create(&scoreCard{ID: 1, Scores: []int32{1, 2}, Weights: []float32{0.5}, Bonus: []*uint16{&bonus, nil}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*spannerpb.ExecuteSqlRequest)
+	for param, want := range map[string]spannerpb.TypeCode{"p2": spannerpb.TypeCode_INT64, "p3": spannerpb.TypeCode_FLOAT64, "p4": spannerpb.TypeCode_INT64} {
+		if g, w := req.ParamTypes[param].GetArrayElementType().GetCode(), want; g != w {
+			t.Fatalf("param element type mismatch for %s\n Got: %v\nWant: %v", param, g, w)
+		}
+	}
+	if g, w := req.Params.Fields["p2"].GetListValue().GetValues()[1].GetStringValue(), "2"; g != w {
+		t.Fatalf("score mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	_ = server.TestSpanner.PutStatementResult("SELECT * FROM `score_cards`", &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &spannerpb.ResultSet{
+			Metadata: &spannerpb.ResultSetMetadata{
+				RowType: &spannerpb.StructType{
+					Fields: []*spannerpb.StructType_Field{
+						{Name: "id", Type: &spannerpb.Type{Code: spannerpb.TypeCode_INT64}},
+						{Name: "scores", Type: &spannerpb.Type{Code: spannerpb.TypeCode_ARRAY, ArrayElementType: &spannerpb.Type{Code: spannerpb.TypeCode_INT64}}},
+						{Name: "weights", Type: &spannerpb.Type{Code: spannerpb.TypeCode_ARRAY, ArrayElementType: &spannerpb.Type{Code: spannerpb.TypeCode_FLOAT64}}},
+						{Name: "bonus", Type: &spannerpb.Type{Code: spannerpb.TypeCode_ARRAY, ArrayElementType: &spannerpb.Type{Code: spannerpb.TypeCode_INT64}}},
+					},
+				},
+			},
+			Rows: []*structpb.ListValue{
+				{Values: []*structpb.Value{
+					structpb.NewStringValue("1"),
+					structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("1"), structpb.NewStringValue("2")}}),
+					structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewNumberValue(0.5)}}),
+					structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("5"), structpb.NewNullValue()}}),
+				}},
+			},
+		},
+	})
+	var cards []scoreCard
+	if err := db.Find(&cards).Error; err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(cards), 1; g != w {
+		t.Fatalf("card count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := cards[0].Scores, []int32{1, 2}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("scores mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := cards[0].Weights, []float32{0.5}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("weights mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := cards[0].Bonus, []*uint16{&bonus, nil}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("bonus mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
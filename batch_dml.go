@@ -0,0 +1,123 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const batchDMLKey = "spanner:batch_dml"
+
+// BatchDML executes all Create, Update and Delete operations that are issued
+// by fc as one batch of DML statements. The statements are buffered locally
+// and sent to Cloud Spanner in one round trip when fc returns without an
+// error. The batch is discarded if fc returns an error.
+//
+// The batch is executed as part of the current transaction if db is in a
+// transaction, and otherwise as a single atomic transaction. BatchDML returns
+// the total number of rows that were affected by the statements in the batch.
+// The number of rows affected by each individual operation in fc is not known
+// while the batch is being buffered and is returned as zero. The Cloud Spanner
+// database/sql driver only returns the total number of affected rows of a
+// batch, and BatchDML can therefore not return the number of rows affected
+// by each statement. If a statement in the batch fails, BatchDML returns the
+// error of that statement, but not the index of the statement in the batch.
+//
+// Save is executed as an INSERT OR UPDATE statement in a batch, as gorm
+// otherwise executes an UPDATE statement and an additional INSERT OR UPDATE
+// statement if the UPDATE statement did not affect any rows.
+//
+// Create operations in a batch do not return any values that are generated by
+// the database. The primary key of a record should therefore be set before it
+// is created in a batch.
+//
+// Example:
+//
+//	rowsAffected, err := spannergorm.BatchDML(db, func(tx *gorm.DB) error {
+//		if err := tx.Create(&singer).Error; err != nil {
+//			return err
+//		}
+//		return tx.Model(&album).Update("title", "New title").Error
+//	})
+func BatchDML(db *gorm.DB, fc func(tx *gorm.DB) error) (int64, error) {
+	ctx := db.Statement.Context
+	conn, release, err := sqlConn(ctx, db.Statement.ConnPool)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	if _, err := conn.ExecContext(ctx, "START BATCH DML"); err != nil {
+		return 0, err
+	}
+	tx := db.Session(&gorm.Session{Context: ctx, SkipDefaultTransaction: true})
+	tx.Statement.Settings.Store(batchDMLKey, true)
+	if !inTransaction(db) {
+		tx.Statement.ConnPool = conn
+	}
+	aborted := true
+	defer func() {
+		// Abort the batch if fc returns an error or panics, so the connection
+		// is not used or returned to the pool with an active batch.
+		if aborted {
+			_, _ = conn.ExecContext(ctx, "ABORT BATCH")
+		}
+	}()
+	if err := fc(tx); err != nil {
+		return 0, err
+	}
+	aborted = false
+	res, err := conn.ExecContext(ctx, "RUN BATCH")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func useBatchDML(db *gorm.DB) bool {
+	if v, ok := db.Get(batchDMLKey); ok {
+		useBatchDML, _ := v.(bool)
+		return useBatchDML
+	}
+	return false
+}
+
+// saveInBatch executes a Save operation in a DML batch as an INSERT OR UPDATE
+// statement. create must be a create callback that does not use a THEN RETURN
+// clause.
+func saveInBatch(db *gorm.DB, create func(*gorm.DB)) {
+	if db.Error != nil {
+		return
+	}
+	// The primary key columns are omitted from the UPDATE statement of Save,
+	// but must be included in the INSERT OR UPDATE statement.
+	omits := make([]string, 0, len(db.Statement.Omits))
+	for _, omit := range db.Statement.Omits {
+		if !isPrimaryKeyColumn(db.Statement.Schema, clause.Column{Name: omit}) {
+			omits = append(omits, omit)
+		}
+	}
+	db.Statement.Omits = omits
+	db.Statement.AddClause(clause.OnConflict{UpdateAll: true})
+	db.Statement.BuildClauses = []string{clause.Insert{}.Name(), clause.Values{}.Name()}
+	create(db)
+	if db.Error == nil {
+		// An INSERT OR UPDATE statement for one record always affects one row.
+		// Returning zero would make gorm execute an additional INSERT OR UPDATE
+		// statement.
+		db.RowsAffected = 1
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestBatchDML(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	insertSQL := "INSERT INTO `singers` (`created_at`,`updated_at`,`deleted_at`,`first_name`,`last_name`,`full_name`,`active`,`id`) VALUES (@p1,@p2,@p3,@p4,@p5,@p6,@p7,@p8)"
+	updateSQL := "UPDATE `singers` SET `first_name`=@p1,`updated_at`=@p2 WHERE `singers`.`deleted_at` IS NULL AND `id` = @p3"
+	_ = server.TestSpanner.PutStatementResult(insertSQL, &testutil.StatementResult{Type: testutil.StatementResultUpdateCount, UpdateCount: 1})
+	_ = server.TestSpanner.PutStatementResult(updateSQL, &testutil.StatementResult{Type: testutil.StatementResultUpdateCount, UpdateCount: 1})
+
+	rowsAffected, err := BatchDML(db, func(tx *gorm.DB) error {
+		if err := tx.Create(&singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&singer{Model: gorm.Model{ID: 2}}).Update("first_name", "First").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := rowsAffected, int64(2); g != w {
+		t.Fatalf("rows affected mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteBatchDmlRequest{}))
+	if g, w := len(batchRequests), 1; g != w {
+		t.Fatalf("batch request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	statements := batchRequests[0].(*spannerpb.ExecuteBatchDmlRequest).Statements
+	if g, w := len(statements), 2; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := statements[0].Sql, insertSQL; g != w {
+		t.Fatalf("insert statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := statements[1].Sql, updateSQL; g != w {
+		t.Fatalf("update statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBatchDMLSave(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	insertOrUpdateSQL := "INSERT OR UPDATE INTO `singers` (`created_at`,`updated_at`,`deleted_at`,`first_name`,`last_name`,`full_name`,`active`,`id`) VALUES (@p1,@p2,@p3,@p4,@p5,@p6,@p7,@p8)"
+	_ = server.TestSpanner.PutStatementResult(insertOrUpdateSQL, &testutil.StatementResult{Type: testutil.StatementResultUpdateCount, UpdateCount: 1})
+
+	// Save is executed as one INSERT OR UPDATE statement in a batch.
+	if _, err := BatchDML(db, func(tx *gorm.DB) error {
+		return tx.Save(&singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}).Error
+	}); err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteBatchDmlRequest{}))
+	if g, w := len(batchRequests), 1; g != w {
+		t.Fatalf("batch request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	statements := batchRequests[0].(*spannerpb.ExecuteBatchDmlRequest).Statements
+	if g, w := len(statements), 1; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := statements[0].Sql, insertOrUpdateSQL; g != w {
+		t.Fatalf("insert or update statement mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBatchDMLAbort(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	wantErr := errors.New("test error")
+	_, err := BatchDML(db, func(tx *gorm.DB) error {
+		if err := tx.Model(&singer{Model: gorm.Model{ID: 1}}).Update("first_name", "First").Error; err != nil {
+			return err
+		}
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("error mismatch\n Got: %v\nWant: %v", err, wantErr)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	batchRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteBatchDmlRequest{}))
+	if g, w := len(batchRequests), 0; g != w {
+		t.Fatalf("batch request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestBatchDMLPanic(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	// The connection pool resets a connection when it is returned to the pool,
+	// so the test uses one connection for the whole test.
+	if err := db.Connection(func(db *gorm.DB) error {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatal("missing expected panic")
+				}
+			}()
+			_, _ = BatchDML(db, func(tx *gorm.DB) error {
+				panic("test panic")
+			})
+		}()
+		conn, release, err := sqlConn(context.Background(), db.Statement.ConnPool)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+			if conn.InDMLBatch() {
+				t.Fatal("DML batch has not been aborted")
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Fields with the tag `gorm:"spanner:commit_timestamp"` are commit timestamp
+// columns. These columns are created with the option
+// allow_commit_timestamp=true, and are always set to the commit timestamp of
+// the transaction when a record is created. A commit timestamp column is also
+// set to the commit timestamp when a record is updated, unless the field is
+// an autoCreateTime field. autoCreateTime commit timestamp columns are never
+// updated.
+//
+// Example:
+//
+//	type Singer struct {
+//		ID        int64
+//		CreatedAt time.Time `gorm:"autoCreateTime;spanner:commit_timestamp"`
+//		UpdatedAt time.Time `gorm:"autoUpdateTime;spanner:commit_timestamp"`
+//	}
+
+// commitTimestampTagValue is the value of the spanner tag setting that marks a
+// field as a commit timestamp column.
+const commitTimestampTagValue = "commit_timestamp"
+
+// pendingCommitTimestamp is the placeholder value for the commit timestamp of
+// a transaction in a DML statement.
+var pendingCommitTimestamp = clause.Expr{SQL: "PENDING_COMMIT_TIMESTAMP()"}
+
+func isCommitTimestampField(field *schema.Field) bool {
+	return field != nil && strings.EqualFold(field.TagSettings["SPANNER"], commitTimestampTagValue)
+}
+
+// commitTimestampField returns the commit timestamp field with the given
+// column name, or nil if the column is not a commit timestamp column.
+func commitTimestampField(s *schema.Schema, column string) *schema.Field {
+	if s == nil {
+		return nil
+	}
+	if field := s.LookUpField(column); isCommitTimestampField(field) {
+		return field
+	}
+	return nil
+}
+
+func hasCommitTimestampFields(s *schema.Schema) bool {
+	if s == nil {
+		return false
+	}
+	for _, field := range s.Fields {
+		if isCommitTimestampField(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildValues builds the VALUES clause of an INSERT statement. The values of
+// commit timestamp columns are replaced with PENDING_COMMIT_TIMESTAMP(), and
+// the values of JSON columns with the json serializer are bound as JSON.
+func buildValues(c clause.Clause, builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok && (hasCommitTimestampFields(stmt.Schema) || hasStandardJSONFields(stmt.Schema)) {
+		if values, ok := c.Expression.(clause.Values); ok {
+			rows := make([][]interface{}, len(values.Values))
+			for i, row := range values.Values {
+				rows[i] = append([]interface{}{}, row...)
+				for j, column := range values.Columns {
+					if commitTimestampField(stmt.Schema, column.Name) != nil {
+						rows[i][j] = pendingCommitTimestamp
+					} else {
+						rows[i][j] = jsonFieldValue(stmt.Schema, column.Name, rows[i][j])
+					}
+				}
+			}
+			values.Values = rows
+			c.Expression = values
+		}
+	}
+	c.Build(builder)
+}
+
+// buildSet builds the SET clause of an UPDATE statement. The values of commit
+// timestamp columns are replaced with PENDING_COMMIT_TIMESTAMP(), and
+// autoCreateTime commit timestamp columns are removed from the clause. The
+// values of JSON columns with the json serializer are bound as JSON.
+func buildSet(c clause.Clause, builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok && (hasCommitTimestampFields(stmt.Schema) || hasStandardJSONFields(stmt.Schema)) {
+		if set, ok := c.Expression.(clause.Set); ok {
+			assignments := make(clause.Set, 0, len(set))
+			for _, assignment := range set {
+				if field := commitTimestampField(stmt.Schema, assignment.Column.Name); field != nil {
+					if field.AutoCreateTime > 0 {
+						continue
+					}
+					assignment.Value = pendingCommitTimestamp
+				} else {
+					assignment.Value = jsonFieldValue(stmt.Schema, assignment.Column.Name, assignment.Value)
+				}
+				assignments = append(assignments, assignment)
+			}
+			c.Expression = assignments
+		}
+	}
+	c.Build(builder)
+}
+
+// buildReturning builds the THEN RETURN clause of a statement. Commit
+// timestamp columns cannot be read in the transaction that writes them, and
+// are therefore not included in the clause.
+func buildReturning(c clause.Clause, builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok || !hasCommitTimestampFields(stmt.Schema) {
+		// TODO: check if we can improve this be returning only required columns.
+		builder.WriteString("THEN RETURN *")
+		return
+	}
+	builder.WriteString("THEN RETURN ")
+	first := true
+	for _, dbName := range stmt.Schema.DBNames {
+		if commitTimestampField(stmt.Schema, dbName) != nil {
+			continue
+		}
+		if !first {
+			builder.WriteByte(',')
+		}
+		first = false
+		builder.WriteQuoted(dbName)
+	}
+}
+
+const (
+	commitTimestampKey = "spanner:commit_timestamp"
+	transactionKey     = "spanner:transaction"
+)
+
+// CommitTimestamp returns the commit timestamp of a write operation or
+// transaction. db must be either a transaction that has been committed, or
+// the result of a Create, Save, Update or Delete operation that was executed
+// outside a transaction. The commit timestamp is not available for operations
+// that use Partitioned DML or batch DML.
+//
+// db.Transaction does not return the transaction that it committed. The
+// commit timestamp of a transaction that is executed with db.Transaction or
+// RunTransaction can only be retrieved by keeping a reference to the
+// transaction that is passed to the function, and calling CommitTimestamp
+// with that transaction after it has been committed.
+//
+// Commit statistics are not supported, as the Cloud Spanner database/sql
+// driver does not return these.
+//
+// Example:
+//
+//	res := db.Create(&singer)
+//	if res.Error != nil {
+//		return res.Error
+//	}
+//	commitTimestamp, err := spannergorm.CommitTimestamp(res)
+//
+//	var tx *gorm.DB
+//	if err := db.Transaction(func(t *gorm.DB) error {
+//		tx = t
+//		return t.Create(&album).Error
+//	}); err != nil {
+//		return err
+//	}
+//	commitTimestamp, err = spannergorm.CommitTimestamp(tx)
+func CommitTimestamp(db *gorm.DB) (time.Time, error) {
+	tx := transactionOf(db)
+	if tx == nil {
+		if v, ok := db.InstanceGet(transactionKey); ok {
+			tx, _ = v.(*connTx)
+		}
+	}
+	if tx != nil {
+		if tx.commitTimestamp.IsZero() {
+			return time.Time{}, fmt.Errorf("spanner: the transaction has not been committed, the commit timestamp is only available after the transaction has been committed")
+		}
+		return tx.commitTimestamp, nil
+	}
+	if v, ok := db.InstanceGet(commitTimestampKey); ok {
+		if commitTimestamp, ok := v.(time.Time); ok {
+			return commitTimestamp, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("spanner: no commit timestamp is available for this statement")
+}
+
+// saveTransaction is a callback that saves the transaction of a statement, so
+// the commit timestamp of the transaction can be retrieved after gorm has
+// committed its default transaction.
+func saveTransaction(db *gorm.DB) {
+	if tx := transactionOf(db); tx != nil {
+		db.InstanceSet(transactionKey, tx)
+	}
+}
+
+// executeWithCommitTimestamp executes a write operation that is not executed
+// in a transaction on a dedicated connection, and saves the commit timestamp
+// of the implicit transaction that the Cloud Spanner database/sql driver uses
+// for the statement. This makes the commit timestamp available for write
+// operations that are executed without the default transaction of gorm.
+func executeWithCommitTimestamp(db *gorm.DB, exec func(db *gorm.DB)) {
+	if db.Error != nil || db.DryRun || inTransaction(db) {
+		exec(db)
+		return
+	}
+	conn, release, err := sqlConn(db.Statement.Context, db.Statement.ConnPool)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	defer release()
+
+	pool := db.Statement.ConnPool
+	db.Statement.ConnPool = conn
+	defer func() {
+		db.Statement.ConnPool = pool
+	}()
+	exec(db)
+	if db.Error != nil {
+		return
+	}
+	// Statements that do not use an implicit read/write transaction do not
+	// have a commit timestamp.
+	_ = rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+		commitTimestamp, err := conn.CommitTimestamp()
+		if err == nil {
+			db.InstanceSet(commitTimestampKey, commitTimestamp)
+		}
+		return err
+	})
+}
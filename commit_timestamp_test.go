@@ -0,0 +1,190 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+type event struct {
+	ID        int64 `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	CreatedAt time.Time `gorm:"autoCreateTime;spanner:commit_timestamp"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime;spanner:commit_timestamp"`
+}
+
+func TestCommitTimestamp(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+
+	for _, test := range []struct {
+		name    string
+		exec    func(tx *gorm.DB) *gorm.DB
+		wantSQL string
+	}{
+		{
+			name: "Create",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Create(&event{ID: 1, Name: "Name"})
+			},
+			wantSQL: "INSERT INTO `events` (`id`,`name`,`created_at`,`updated_at`) VALUES (?,?,PENDING_COMMIT_TIMESTAMP(),PENDING_COMMIT_TIMESTAMP())",
+		},
+		{
+			name: "Update",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Model(&event{ID: 1}).Update("name", "Name")
+			},
+			wantSQL: "UPDATE `events` SET `name`=?,`updated_at`=PENDING_COMMIT_TIMESTAMP() WHERE `id` = ?",
+		},
+		{
+			name: "Save",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Save(&event{ID: 1, Name: "Name"})
+			},
+			wantSQL: "UPDATE `events` SET `name`=?,`updated_at`=PENDING_COMMIT_TIMESTAMP() WHERE `id` = ?",
+		},
+	} {
+		tx := test.exec(dryRun)
+		if tx.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, tx.Error)
+		}
+		if g, w := tx.Statement.SQL.String(), test.wantSQL; g != w {
+			t.Fatalf("%s: sql mismatch\n Got: %s\nWant: %s", test.name, g, w)
+		}
+	}
+}
+
+func TestCommitTimestampOfTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	tx := db.Begin()
+	if err := tx.Scopes(WithMutations).Create(&singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CommitTimestamp(tx); err == nil {
+		t.Fatal("missing expected error for transaction that has not been committed")
+	}
+	if err := tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+	commitTimestamp, err := CommitTimestamp(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitTimestamp.IsZero() {
+		t.Fatal("missing commit timestamp")
+	}
+	// The mutation is buffered in the transaction and sent with the commit.
+	if g, w := len(committedMutations(t, server.TestSpanner)), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestCommitTimestampOfDefaultTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	res := db.Scopes(WithMutations).Create(&singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	commitTimestamp, err := CommitTimestamp(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitTimestamp.IsZero() {
+		t.Fatal("missing commit timestamp")
+	}
+
+	res = db.Session(&gorm.Session{SkipDefaultTransaction: true}).Scopes(WithMutations).Create(&singer{Model: gorm.Model{ID: 2}, FirstName: "First", LastName: "Last"})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	commitTimestamp, err = CommitTimestamp(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitTimestamp.IsZero() {
+		t.Fatal("missing commit timestamp")
+	}
+}
+
+func TestCommitTimestampWithoutDefaultTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(updateSingerSQL, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	res := db.Session(&gorm.Session{SkipDefaultTransaction: true}).Model(&singer{Model: gorm.Model{ID: 1}}).Update("first_name", "First")
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	commitTimestamp, err := CommitTimestamp(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitTimestamp.IsZero() {
+		t.Fatal("missing commit timestamp")
+	}
+}
+
+func TestCommitTimestampOfClosureTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	var committed *gorm.DB
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		committed = tx
+		if err := tx.Scopes(WithMutations).Create(&singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}).Error; err != nil {
+			return err
+		}
+		if _, err := CommitTimestamp(tx); err == nil {
+			t.Fatal("missing expected error for transaction that has not been committed")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	commitTimestamp, err := CommitTimestamp(committed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commitTimestamp.IsZero() {
+		t.Fatal("missing commit timestamp")
+	}
+	// The mutation is buffered in the transaction and sent with the commit.
+	if g, w := len(committedMutations(t, server.TestSpanner)), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
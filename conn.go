@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+)
+
+// connPool wraps a *sql.DB and starts each transaction on a dedicated
+// *sql.Conn. This makes it possible to access the underlying SpannerConn while
+// a transaction is active, which is not possible through a plain *sql.Tx.
+type connPool struct {
+	*sql.DB
+
+	// priorities contains the connection pools for each priority. It is nil
+	// if the pool has not been opened by the Dialector.
+	priorities *priorityPools
+}
+
+func (p *connPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &connTx{Tx: tx, db: p.DB, conn: conn, opts: opts}, nil
+}
+
+func (p *connPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+// connTx is a transaction on a dedicated *sql.Conn. The connection is returned
+// to the pool when the transaction is committed or rolled back. The
+// transaction records the statements that it executes, so it can emulate
+// savepoints.
+type connTx struct {
+	*sql.Tx
+	db   *sql.DB
+	conn *sql.Conn
+	opts *sql.TxOptions
+
+	statements []transactionStatement
+	savepoints []savepoint
+	// err is set if the transaction could not be rolled back to a savepoint.
+	// The transaction can no longer be used, and returns err when it is
+	// committed.
+	err error
+
+	// commitTimestamp is the commit timestamp of the transaction. It is only
+	// set after the transaction has been committed successfully.
+	commitTimestamp time.Time
+}
+
+// GetDBConn returns the *sql.DB of the transaction, so gorm's DB() also works
+// in a transaction.
+func (tx *connTx) GetDBConn() (*sql.DB, error) {
+	if tx.db == nil {
+		return nil, gorm.ErrInvalidDB
+	}
+	return tx.db, nil
+}
+
+func (tx *connTx) Commit() error {
+	err := tx.commit()
+	_ = tx.conn.Close()
+	return err
+}
+
+// commit commits the transaction without closing the connection, and records
+// the commit timestamp of the transaction.
+func (tx *connTx) commit() error {
+	if tx.err != nil {
+		return tx.err
+	}
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+	// Read-only transactions do not have a commit timestamp.
+	_ = rawSpannerConn(tx.conn, func(conn spannerdriver.SpannerConn) error {
+		ts, err := conn.CommitTimestamp()
+		if err == nil {
+			tx.commitTimestamp = ts
+		}
+		return err
+	})
+	return nil
+}
+
+// readOnly returns true if tx is a read-only transaction.
+func (tx *connTx) readOnly() bool {
+	return tx.opts != nil && tx.opts.ReadOnly
+}
+
+func (tx *connTx) Rollback() error {
+	err := tx.Tx.Rollback()
+	if tx.err != nil {
+		// The transaction was already rolled back when it could not be
+		// rolled back to a savepoint.
+		err = nil
+	}
+	_ = tx.conn.Close()
+	return err
+}
+
+// inTransaction returns true if the given statement is executed as part of a
+// transaction.
+func inTransaction(db *gorm.DB) bool {
+	switch db.Statement.ConnPool.(type) {
+	case *connTx, *gorm.PreparedStmtTX, *sql.Tx:
+		return true
+	}
+	return false
+}
+
+// withSpannerConn executes f with the Spanner connection that is used by the
+// given statement. The connection is the connection of the current transaction
+// if the statement is executed in a transaction.
+func withSpannerConn(db *gorm.DB, f func(conn spannerdriver.SpannerConn) error) error {
+	conn, release, err := sqlConn(db.Statement.Context, db.Statement.ConnPool)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return rawSpannerConn(conn, f)
+}
+
+// rawSpannerConn executes f with the Spanner connection of the given *sql.Conn.
+func rawSpannerConn(conn *sql.Conn, f func(conn spannerdriver.SpannerConn) error) error {
+	return conn.Raw(func(driverConn interface{}) error {
+		spannerConn, ok := driverConn.(spannerdriver.SpannerConn)
+		if !ok {
+			return fmt.Errorf("spanner: unexpected driver connection type: %T", driverConn)
+		}
+		return f(spannerConn)
+	})
+}
+
+func sqlConn(ctx context.Context, pool gorm.ConnPool) (*sql.Conn, func(), error) {
+	switch p := pool.(type) {
+	case *sql.Conn:
+		return p, func() {}, nil
+	case *connTx:
+		return p.conn, func() {}, nil
+	case *gorm.PreparedStmtTX:
+		return sqlConn(ctx, p.Tx)
+	case *gorm.PreparedStmtDB:
+		return sqlConn(ctx, p.ConnPool)
+	case *connPool:
+		return sqlConn(ctx, p.DB)
+	case *sql.DB:
+		conn, err := p.Conn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, func() { _ = conn.Close() }, nil
+	}
+	return nil, nil, fmt.Errorf("spanner: cannot get the Spanner connection from a connection pool of type %T", pool)
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// numericPrecision and numericScale are the fixed precision and scale of
+	// the Cloud Spanner NUMERIC data type.
+	numericPrecision = 38
+	numericScale     = 9
+)
+
+var (
+	dataTypesMu sync.RWMutex
+	// dataTypes contains the Cloud Spanner data types that have been
+	// registered with RegisterDataType.
+	dataTypes = map[reflect.Type]string{}
+)
+
+func init() {
+	schema.RegisterSerializer(dateSerializerName, dateSerializer{})
+	schema.RegisterSerializer(jsonSerializerName, jsonSerializer{})
+	schema.RegisterSerializer("json", standardJSONSerializer{})
+	schema.RegisterSerializer(arraySerializerName, arraySerializer{})
+	schema.RegisterSerializer(protoSerializerName, protoSerializer{})
+}
+
+// RegisterDataType registers the Cloud Spanner data type that is used by
+// AutoMigrate for fields with the same type as value. This can be used for
+// custom types for which gorm cannot derive the correct data type. Values of
+// the type must be supported by the Cloud Spanner database/sql driver, or the
+// type must implement driver.Valuer and return a supported value, e.g. a
+// *big.Rat or spanner.NullNumeric for a NUMERIC column. A data type that is
+// set with the type tag of a field takes precedence over a registered type.
+//
+// RegisterDataType cannot be used for struct types that gorm parses as an
+// association, such as big.Rat. Fields of these types must set the data type
+// with the type tag instead, e.g. `gorm:"type:numeric"`.
+//
+// Example:
+//
+//	spannergorm.RegisterDataType(Money{}, "NUMERIC")
+func RegisterDataType(value interface{}, dataType string) {
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	dataTypesMu.Lock()
+	defer dataTypesMu.Unlock()
+	dataTypes[t] = dataType
+}
+
+// registeredDataType returns the registered data type of the type of the
+// given field.
+func registeredDataType(field *schema.Field) (string, bool) {
+	if field.IndirectFieldType == nil {
+		return "", false
+	}
+	dataTypesMu.RLock()
+	defer dataTypesMu.RUnlock()
+	dataType, ok := dataTypes[field.IndirectFieldType]
+	return dataType, ok
+}
+
+// validateNumeric returns an error if the precision and scale of a NUMERIC
+// field cannot be stored in a Cloud Spanner NUMERIC column, which supports at
+// most 29 digits before and 9 digits after the decimal point.
+func validateNumeric(field *schema.Field) error {
+	if field.Scale > numericScale || field.Precision-field.Scale > numericPrecision-numericScale {
+		return fmt.Errorf("spanner: field %s has precision %d and scale %d, but NUMERIC supports at most %d digits before and %d digits after the decimal point",
+			field.Name, field.Precision, field.Scale, numericPrecision-numericScale, numericScale)
+	}
+	return nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// isFloat32Type returns whether t is a float32, a pointer to a float32 or a
+// slice of float32 values that does not implement driver.Valuer. The Cloud
+// Spanner database/sql driver cannot bind these values, and this dialect does
+// not support FLOAT32 columns. float32 fields are therefore stored in FLOAT64
+// columns.
+func isFloat32Type(t reflect.Type) bool {
+	if t == nil || t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return isFloat32Type(t.Elem())
+	}
+	return t.Kind() == reflect.Float32
+}
+
+// float64Value converts a float32 value, a pointer to a float32 value or a
+// slice of these to the corresponding float64 type, so it can be bound as a
+// FLOAT64 value. Other values are returned unchanged.
+func float64Value(v interface{}) interface{} {
+	if !isFloat32Type(reflect.TypeOf(v)) {
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return (*float64)(nil)
+		}
+		f := rv.Elem().Float()
+		return &f
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Ptr {
+			result := make([]*float64, rv.Len())
+			for i := range result {
+				result[i], _ = float64Value(rv.Index(i).Interface()).(*float64)
+			}
+			return result
+		}
+		if rv.IsNil() {
+			return []float64(nil)
+		}
+		result := make([]float64, rv.Len())
+		for i := range result {
+			result[i] = rv.Index(i).Float()
+		}
+		return result
+	}
+	return rv.Float()
+}
+
+// serializerDataType returns the data type of a field that uses one of the
+// serializers of this package.
+func (dialector Dialector) serializerDataType(field *schema.Field) (string, bool) {
+	switch field.Serializer.(type) {
+	case dateSerializer:
+		return "DATE", true
+	case jsonSerializer:
+		return "JSON", true
+	case arraySerializer:
+		return dialector.arrayDataType(field), true
+	case protoSerializer:
+		if isProtoEnumField(field) {
+			return "INT64", true
+		}
+		return dialector.DataTypeOf(&schema.Field{DataType: schema.Bytes, Size: field.Size}), true
+	}
+	return "", false
+}
+
+// validateSerializer returns an error if the field must use one of the
+// serializers of this package to be stored in a column with the given data
+// type.
+func validateSerializer(field *schema.Field, dataType string) error {
+	var name string
+	switch dataType = strings.ToUpper(dataType); {
+	case dataType == "DATE" && field.IndirectFieldType == timeType:
+		if _, ok := field.Serializer.(dateSerializer); !ok {
+			name = dateSerializerName
+		}
+	case dataType == "JSON" && field.Serializer != nil:
+		switch field.Serializer.(type) {
+		case jsonSerializer, standardJSONSerializer:
+		default:
+			name = jsonSerializerName
+		}
+	case strings.HasPrefix(dataType, "ARRAY") && isSliceField(field):
+		if _, ok := field.Serializer.(arraySerializer); !ok {
+			name = arraySerializerName
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	return fmt.Errorf("spanner: field %s has type %v, which can only be stored in a column of type %s with the tag `gorm:\"serializer:%s\"`",
+		field.Name, field.FieldType, dataType, name)
+}
+
+// valueLiteral returns the literal for the given value if it is a value that
+// is not rendered correctly by gorm. The values of fields that use a
+// serializer are rendered as the value that is returned by the serializer.
+func valueLiteral(v interface{}) (string, bool) {
+	if literal, ok := typedValueLiteral(v); ok {
+		return literal, true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return "", false
+		}
+		// Slices are bound as ARRAY parameters by the Cloud Spanner
+		// database/sql driver.
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			return arrayLiteral(arrayValue{value: value})
+		}
+		return typedValueLiteral(value)
+	}
+	return "", false
+}
+
+func typedValueLiteral(v interface{}) (string, bool) {
+	if literal, ok := dateLiteral(v); ok {
+		return literal, true
+	}
+	if literal, ok := arrayLiteral(v); ok {
+		return literal, true
+	}
+	return jsonLiteral(v)
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestNumericFields(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	insertSQL := "INSERT INTO `accounts` (`id`,`balance`,`limit`,`fee`) VALUES (@p1,@p2,@p3,@p4)"
+	_ = server.TestSpanner.PutStatementResult(insertSQL, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	a := account{
+		ID:      1,
+		Balance: *big.NewRat(123456, 1000),
+		Limit:   spanner.NullNumeric{Numeric: *big.NewRat(1000, 1), Valid: true},
+	}
+	if err := db.Create(&a).Error; err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*spannerpb.ExecuteSqlRequest)
+	for param, want := range map[string]string{"p2": "123.456000000", "p3": "1000.000000000"} {
+		if g, w := req.ParamTypes[param].GetCode(), spannerpb.TypeCode_NUMERIC; g != w {
+			t.Fatalf("param type mismatch for %s\n Got: %v\nWant: %v", param, g, w)
+		}
+		if g, w := req.Params.Fields[param].GetStringValue(), want; g != w {
+			t.Fatalf("param value mismatch for %s\n Got: %v\nWant: %v", param, g, w)
+		}
+	}
+	// The nil Fee is bound as NULL.
+	if _, ok := req.Params.Fields["p4"].GetKind().(*structpb.Value_NullValue); !ok {
+		t.Fatalf("param value mismatch for p4\n Got: %v\nWant: NULL", req.Params.Fields["p4"])
+	}
+
+	query := "SELECT * FROM `accounts`"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type: testutil.StatementResultResultSet,
+		ResultSet: &spannerpb.ResultSet{
+			Metadata: &spannerpb.ResultSetMetadata{
+				RowType: &spannerpb.StructType{
+					Fields: []*spannerpb.StructType_Field{
+						{Name: "id", Type: &spannerpb.Type{Code: spannerpb.TypeCode_INT64}},
+						{Name: "balance", Type: &spannerpb.Type{Code: spannerpb.TypeCode_NUMERIC}},
+						{Name: "limit", Type: &spannerpb.Type{Code: spannerpb.TypeCode_NUMERIC}},
+						{Name: "fee", Type: &spannerpb.Type{Code: spannerpb.TypeCode_NUMERIC}},
+					},
+				},
+			},
+			Rows: []*structpb.ListValue{
+				{Values: []*structpb.Value{
+					structpb.NewStringValue("1"),
+					structpb.NewStringValue("123.456000000"),
+					structpb.NewStringValue("1000.000000000"),
+					structpb.NewNullValue(),
+				}},
+			},
+		},
+	})
+	var accounts []account
+	if err := db.Find(&accounts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(accounts), 1; g != w {
+		t.Fatalf("account count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := &accounts[0].Balance, big.NewRat(123456, 1000); g.Cmp(w) != 0 {
+		t.Fatalf("balance mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := accounts[0].Limit, (spanner.NullNumeric{Numeric: *big.NewRat(1000, 1), Valid: true}); !g.Valid || g.Numeric.Cmp(&w.Numeric) != 0 {
+		t.Fatalf("limit mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if fee := accounts[0].Fee; fee != nil && fee.Valid {
+		t.Fatalf("fee mismatch\n Got: %v\nWant: %v", fee, nil)
+	}
+}
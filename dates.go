@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"gorm.io/gorm/schema"
+)
+
+// Fields of type civil.Date and spanner.NullDate are bound and scanned as
+// DATE values by the Cloud Spanner database/sql driver. time.Time fields with
+// the tag `gorm:"serializer:spanner_date"` are also stored in DATE columns.
+// These fields are converted to civil.Date when they are bound as a
+// parameter, and the DATE values that are read from the database are
+// converted to a time.Time at midnight UTC. The tag `gorm:"type:date"` is
+// not enough for time.Time fields, as database/sql cannot scan a DATE value
+// into a time.Time, and AutoMigrate returns an error for these fields.
+//
+// Example:
+//
+//	type Singer struct {
+//		ID        int64
+//		BirthDate time.Time `gorm:"serializer:spanner_date"`
+//	}
+
+// dateSerializerName is the name of the serializer for time.Time fields that
+// are stored in DATE columns.
+const dateSerializerName = "spanner_date"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// dateSerializer binds a time.Time field as a civil.Date, and sets the field
+// to midnight UTC of the DATE value that is read from the database.
+type dateSerializer struct{}
+
+// Scan implements schema.SerializerInterface.
+func (dateSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var date spanner.NullDate
+	if err := date.Scan(dbValue); err != nil {
+		return err
+	}
+	if !date.Valid {
+		field.ReflectValueOf(ctx, dst).Set(reflect.Zero(field.FieldType))
+		return nil
+	}
+	return field.Set(ctx, dst, date.Date.In(time.UTC))
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (dateSerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	switch t := fieldValue.(type) {
+	case time.Time:
+		return civil.DateOf(t), nil
+	case *time.Time:
+		if t == nil {
+			return (*civil.Date)(nil), nil
+		}
+		return civil.DateOf(*t), nil
+	}
+	return nil, fmt.Errorf("spanner: field %s has type %v, which cannot be stored in a DATE column", field.Name, field.FieldType)
+}
+
+// dateLiteral returns the DATE literal for the given value if it is a date.
+func dateLiteral(v interface{}) (string, bool) {
+	switch d := v.(type) {
+	case civil.Date:
+		return "DATE '" + d.String() + "'", true
+	case *civil.Date:
+		if d == nil {
+			return "NULL", true
+		}
+		return dateLiteral(*d)
+	case spanner.NullDate:
+		if !d.Valid {
+			return "NULL", true
+		}
+		return dateLiteral(d.Date)
+	case *spanner.NullDate:
+		if d == nil {
+			return "NULL", true
+		}
+		return dateLiteral(*d)
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"gorm.io/gorm"
+)
+
+type concert struct {
+	ID        int64      `gorm:"primarykey;autoIncrement:false"`
+	StartDate time.Time  `gorm:"serializer:spanner_date"`
+	EndDate   *time.Time `gorm:"serializer:spanner_date"`
+	OnSale    civil.Date `gorm:"type:date"`
+	Cancelled spanner.NullDate
+}
+
+func TestDateFields(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	c := concert{
+		ID:        1,
+		StartDate: time.Date(2023, 6, 1, 20, 0, 0, 0, time.UTC),
+		OnSale:    civil.Date{Year: 2023, Month: 1, Day: 15},
+	}
+	res := db.Session(&gorm.Session{DryRun: true}).Create(&c)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	// The time.Time fields are bound as the civil.Date that is returned by
+	// their serializer.
+	want := []interface{}{
+		int64(1),
+		civil.Date{Year: 2023, Month: 6, Day: 1},
+		(*civil.Date)(nil),
+		civil.Date{Year: 2023, Month: 1, Day: 15},
+		spanner.NullDate{},
+	}
+	vars := append([]interface{}{}, res.Statement.Vars...)
+	for _, i := range []int{1, 2} {
+		var err error
+		if vars[i], err = vars[i].(driver.Valuer).Value(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g, w := vars, want; !reflect.DeepEqual(g, w) {
+		t.Fatalf("vars mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := db.Dialector.Explain(res.Statement.SQL.String(), res.Statement.Vars...),
+		"INSERT INTO `concerts` (`id`,`start_date`,`end_date`,`on_sale`,`cancelled`) VALUES (1,DATE '2023-06-01',NULL,DATE '2023-01-15',NULL)"; g != w {
+		t.Fatalf("explain mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	field := res.Statement.Schema.LookUpField("StartDate")
+	value := field.NewValuePool.Get()
+	if err := value.(sql.Scanner).Scan(civil.Date{Year: 2023, Month: 7, Day: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var scanned concert
+	if err := field.Set(context.Background(), reflect.ValueOf(&scanned).Elem(), value); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := scanned.StartDate, time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC); !g.Equal(w) {
+		t.Fatalf("start date mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
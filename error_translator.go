@@ -0,0 +1,90 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"errors"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"gorm.io/gorm"
+)
+
+// ErrCheckConstraintViolated is returned for statements that violate a check
+// constraint when gorm.Config.TranslateError is enabled. gorm does not define
+// an error for check constraint violations.
+var ErrCheckConstraintViolated = errors.New("violates check constraint")
+
+// translatedError is a Cloud Spanner error that has been translated to a gorm
+// error. errors.Is returns true for both the gorm error and the original
+// error, and errors.As can be used to get the original *spanner.Error.
+type translatedError struct {
+	translated error
+	err        error
+}
+
+func (e *translatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *translatedError) Is(target error) bool {
+	return target == e.translated
+}
+
+func (e *translatedError) Unwrap() error {
+	return e.err
+}
+
+// Translate implements gorm.ErrorTranslator. It translates Cloud Spanner
+// errors to the corresponding gorm errors when gorm.Config.TranslateError is
+// enabled:
+//   - AlreadyExists is translated to gorm.ErrDuplicatedKey.
+//   - Foreign key constraint violations are translated to
+//     gorm.ErrForeignKeyViolated.
+//   - Check constraint violations are translated to
+//     ErrCheckConstraintViolated.
+//   - NotFound errors for updates of rows that do not exist, which are
+//     returned for updates that use mutations, are translated to
+//     gorm.ErrRecordNotFound.
+//
+// Other errors are returned unmodified.
+func (dialector Dialector) Translate(err error) error {
+	var translated error
+	switch spanner.ErrCode(err) {
+	case codes.AlreadyExists:
+		translated = gorm.ErrDuplicatedKey
+	case codes.FailedPrecondition:
+		if containsIgnoreCase(spanner.ErrDesc(err), "foreign key constraint") {
+			translated = gorm.ErrForeignKeyViolated
+		}
+	case codes.OutOfRange:
+		if containsIgnoreCase(spanner.ErrDesc(err), "check constraint") {
+			translated = ErrCheckConstraintViolated
+		}
+	case codes.NotFound:
+		if containsIgnoreCase(spanner.ErrDesc(err), "row cannot be updated") {
+			translated = gorm.ErrRecordNotFound
+		}
+	}
+	if translated == nil {
+		return err
+	}
+	return &translatedError{translated: translated, err: err}
+}
+
+func containsIgnoreCase(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), substr)
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+func TestTranslate(t *testing.T) {
+	t.Parallel()
+
+	dialector := Dialector{Config: &Config{}}
+	for _, test := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "already exists",
+			err:  spanner.ToSpannerError(status.Error(codes.AlreadyExists, "Row [1] in table Singers already exists")),
+			want: gorm.ErrDuplicatedKey,
+		},
+		{
+			name: "foreign key",
+			err:  spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "Foreign key constraint `FK_Albums_Singers` is violated on table `Albums`. Cannot find referenced values in Singers(id).")),
+			want: gorm.ErrForeignKeyViolated,
+		},
+		{
+			name: "check constraint",
+			err:  spanner.ToSpannerError(status.Error(codes.OutOfRange, "Check constraint `Singers`.`chk_name` is violated for key (1)")),
+			want: ErrCheckConstraintViolated,
+		},
+		{
+			name: "row not found",
+			err:  spanner.ToSpannerError(status.Error(codes.NotFound, "Row [1] in table Singers is missing. Row cannot be updated.")),
+			want: gorm.ErrRecordNotFound,
+		},
+		{
+			name: "other failed precondition",
+			err:  spanner.ToSpannerError(status.Error(codes.FailedPrecondition, "Cannot add NOT NULL column")),
+		},
+		{
+			name: "other not found",
+			err:  spanner.ToSpannerError(status.Error(codes.NotFound, "Database not found")),
+		},
+	} {
+		err := dialector.Translate(test.err)
+		if test.want == nil {
+			if err != test.err {
+				t.Errorf("%s: error mismatch\n Got: %v\nWant: %v", test.name, err, test.err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: error mismatch\n Got: %v\nWant: %v", test.name, err, test.want)
+		}
+		var spannerErr *spanner.Error
+		if !errors.As(err, &spannerErr) {
+			t.Errorf("%s: translated error does not wrap a *spanner.Error: %v", test.name, err)
+		}
+		if g, w := spanner.ErrCode(err), spanner.ErrCode(test.err); g != w {
+			t.Errorf("%s: error code mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/googleapis/go-gorm-spanner
 go 1.19
 
 require (
+	cloud.google.com/go v0.110.8
 	cloud.google.com/go/longrunning v0.5.3
 	cloud.google.com/go/spanner v1.51.1-0.20231030142734-7abc3595e9cc
 	github.com/golang/protobuf v1.5.3
@@ -15,7 +16,6 @@ require (
 )
 
 require (
-	cloud.google.com/go v0.110.8 // indirect
 	cloud.google.com/go/compute v1.23.0 // indirect
 	cloud.google.com/go/compute/metadata v0.2.3 // indirect
 	cloud.google.com/go/iam v1.1.2 // indirect
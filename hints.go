@@ -15,10 +15,25 @@
 package gorm
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// The statement hints and join hints of a statement are stored in clauses
+// with the following names. These clauses are never built directly. Instead,
+// statement hints are added to the first clause of the statement by
+// applyStatementHints, and join hints are added to the joins by buildFrom.
+const (
+	statementHintsClauseName = "SPANNER STATEMENT HINTS"
+	joinHintsClausePrefix    = "SPANNER JOIN HINTS "
+	joinTableHintsPrefix     = "SPANNER JOIN TABLE HINTS "
+)
+
 type Exprs []clause.Expression
 
 func (exprs Exprs) Build(builder clause.Builder) {
@@ -30,32 +45,439 @@ func (exprs Exprs) Build(builder clause.Builder) {
 	}
 }
 
+// hint is a single hint in a Cloud Spanner hint expression.
+type hint interface {
+	hintKey() string
+	buildHint(builder clause.Builder)
+}
+
+// hintExpression is a Cloud Spanner hint expression with one or more hints,
+// e.g. @{FORCE_INDEX=`idx_singers_name`,SCAN_METHOD=BATCH}.
+type hintExpression []hint
+
+func (hints hintExpression) Build(builder clause.Builder) {
+	builder.WriteString("@{")
+	for idx, h := range hints {
+		if idx > 0 {
+			builder.WriteByte(',')
+		}
+		h.buildHint(builder)
+	}
+	builder.WriteByte('}')
+}
+
+// with returns a copy of the hint expression with the given hint added. The
+// hint replaces any existing hint with the same key.
+func (hints hintExpression) with(h hint) hintExpression {
+	result := make(hintExpression, 0, len(hints)+1)
+	for _, existing := range hints {
+		if existing.hintKey() != h.hintKey() {
+			result = append(result, existing)
+		}
+	}
+	return append(result, h)
+}
+
+// addTableHint adds a hint to the hint expression after the table in the FROM
+// clause of the statement.
+func addTableHint(stmt *gorm.Statement, h hint) {
+	c := stmt.Clauses["FROM"]
+	switch expr := c.AfterExpression.(type) {
+	case nil:
+		c.AfterExpression = hintExpression{h}
+	case hintExpression:
+		c.AfterExpression = expr.with(h)
+	case Exprs:
+		if hints, ok := expr[len(expr)-1].(hintExpression); ok {
+			c.AfterExpression = append(append(Exprs{}, expr[:len(expr)-1]...), hints.with(h))
+		} else {
+			c.AfterExpression = append(append(Exprs{}, expr...), hintExpression{h})
+		}
+	default:
+		c.AfterExpression = Exprs{expr, hintExpression{h}}
+	}
+	stmt.Clauses["FROM"] = c
+}
+
+// addStatementHint adds a hint to the statement hints of the statement.
+func addStatementHint(stmt *gorm.Statement, h hint) {
+	addHint(stmt, statementHintsClauseName, h)
+}
+
+// addHint adds a hint to the hint expression in the clause with the given
+// name.
+func addHint(stmt *gorm.Statement, name string, h hint) {
+	c := stmt.Clauses[name]
+	hints, _ := c.Expression.(hintExpression)
+	c.Expression = hints.with(h)
+	stmt.Clauses[name] = c
+}
+
+// hintsOf returns the hint expression in the clause with the given name.
+func hintsOf(stmt *gorm.Statement, name string) hintExpression {
+	hints, _ := stmt.Clauses[name].Expression.(hintExpression)
+	return hints
+}
+
+// applyStatementHints returns a callback that adds the statement hints of a
+// statement before the clause with the given name. This must be the first
+// clause of the statement.
+func applyStatementHints(name string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		hints := hintsOf(db.Statement, statementHintsClauseName)
+		if len(hints) == 0 {
+			return
+		}
+		c := db.Statement.Clauses[name]
+		c.BeforeExpression = hints
+		db.Statement.Clauses[name] = c
+	}
+}
+
 type IndexHint struct {
 	Type string
 	Key  string
 }
 
 func (indexHint IndexHint) ModifyStatement(stmt *gorm.Statement) {
-	clause := stmt.Clauses["FROM"]
-
-	if clause.AfterExpression == nil {
-		clause.AfterExpression = indexHint
-	} else {
-		clause.AfterExpression = Exprs{clause.AfterExpression, indexHint}
+	if indexHint.Key != "" {
+		addTableHint(stmt, indexHint)
 	}
-
-	stmt.Clauses["FROM"] = clause
 }
 
 func (indexHint IndexHint) Build(builder clause.Builder) {
 	if indexHint.Key != "" {
-		builder.WriteString("@{")
-		builder.WriteString(indexHint.Type)
-		builder.WriteQuoted(indexHint.Key)
-		builder.WriteByte('}')
+		hintExpression{indexHint}.Build(builder)
 	}
 }
 
+func (indexHint IndexHint) hintKey() string {
+	return strings.TrimSuffix(indexHint.Type, "=")
+}
+
+func (indexHint IndexHint) buildHint(builder clause.Builder) {
+	builder.WriteString(indexHint.Type)
+	builder.WriteQuoted(indexHint.Key)
+}
+
 func ForceIndex(name string) IndexHint {
 	return IndexHint{Type: "FORCE_INDEX=", Key: name}
 }
+
+// TableHint is a Cloud Spanner table hint. Table hints are added after the
+// table in the FROM clause of a query, and are combined with any other table
+// hints, including index hints, in one hint expression.
+type TableHint struct {
+	Key   string
+	Value string
+}
+
+func (tableHint TableHint) ModifyStatement(stmt *gorm.Statement) {
+	addTableHint(stmt, tableHint)
+}
+
+func (tableHint TableHint) Build(builder clause.Builder) {
+	hintExpression{tableHint}.Build(builder)
+}
+
+func (tableHint TableHint) hintKey() string {
+	return tableHint.Key
+}
+
+func (tableHint TableHint) buildHint(builder clause.Builder) {
+	builder.WriteString(tableHint.Key)
+	builder.WriteByte('=')
+	builder.WriteString(tableHint.Value)
+}
+
+// GroupByScanOptimization returns a table hint that enables or disables the
+// group by scan optimization for the table.
+func GroupByScanOptimization(enabled bool) TableHint {
+	return TableHint{Key: "GROUPBY_SCAN_OPTIMIZATION", Value: boolHintValue(enabled)}
+}
+
+// ScanMethod returns a table hint that sets the scan method (AUTO, BATCH or
+// ROW) for the table.
+func ScanMethod(method string) TableHint {
+	return TableHint{Key: "SCAN_METHOD", Value: method}
+}
+
+// IndexStrategy returns a table hint that sets the index strategy for the
+// table, e.g. FORCE_INDEX_UNION.
+func IndexStrategy(strategy string) TableHint {
+	return TableHint{Key: "INDEX_STRATEGY", Value: strategy}
+}
+
+// StatementHint is a Cloud Spanner statement hint. Statement hints are added
+// before the first keyword of a query or DML statement, and are combined with
+// any other statement hints in one hint expression.
+type StatementHint struct {
+	Key   string
+	Value string
+}
+
+func (statementHint StatementHint) ModifyStatement(stmt *gorm.Statement) {
+	addStatementHint(stmt, statementHint)
+}
+
+func (statementHint StatementHint) Build(builder clause.Builder) {
+	hintExpression{statementHint}.Build(builder)
+}
+
+func (statementHint StatementHint) hintKey() string {
+	return statementHint.Key
+}
+
+func (statementHint StatementHint) buildHint(builder clause.Builder) {
+	builder.WriteString(statementHint.Key)
+	builder.WriteByte('=')
+	builder.WriteString(statementHint.Value)
+}
+
+// UseAdditionalParallelism returns a statement hint that instructs Cloud
+// Spanner to use additional parallelism to execute the statement.
+func UseAdditionalParallelism(enabled bool) StatementHint {
+	return StatementHint{Key: "USE_ADDITIONAL_PARALLELISM", Value: boolHintValue(enabled)}
+}
+
+// OptimizerVersion returns a statement hint that sets the query optimizer
+// version, e.g. "6" or "latest_version".
+func OptimizerVersion(version string) StatementHint {
+	return StatementHint{Key: "OPTIMIZER_VERSION", Value: version}
+}
+
+// OptimizerStatisticsPackage returns a statement hint that sets the query
+// optimizer statistics package, e.g. "latest".
+func OptimizerStatisticsPackage(name string) StatementHint {
+	return StatementHint{Key: "OPTIMIZER_STATISTICS_PACKAGE", Value: name}
+}
+
+// AllowDistributedMerge returns a statement hint that enables or disables the
+// distributed merge algorithm for ORDER BY queries.
+func AllowDistributedMerge(allow bool) StatementHint {
+	return StatementHint{Key: "ALLOW_DISTRIBUTED_MERGE", Value: boolHintValue(allow)}
+}
+
+// JoinHint is a Cloud Spanner join hint for a join that is added with Joins.
+// Join hints are added after the JOIN keyword of the join.
+type JoinHint struct {
+	// Join is the name of the association that is joined, as passed to Joins,
+	// e.g. "Albums" or "Singer.Albums", or the name of the joined table.
+	Join  string
+	Key   string
+	Value string
+}
+
+func (joinHint JoinHint) ModifyStatement(stmt *gorm.Statement) {
+	addHint(stmt, joinHintsClausePrefix+joinHint.Join, joinHint)
+}
+
+func (joinHint JoinHint) Build(builder clause.Builder) {
+	hintExpression{joinHint}.Build(builder)
+}
+
+func (joinHint JoinHint) hintKey() string {
+	return joinHint.Key
+}
+
+func (joinHint JoinHint) buildHint(builder clause.Builder) {
+	builder.WriteString(joinHint.Key)
+	builder.WriteByte('=')
+	builder.WriteString(joinHint.Value)
+}
+
+// JoinMethod returns a join hint that sets the join method, e.g. HASH_JOIN or
+// APPLY_JOIN, for the given join.
+func JoinMethod(join, method string) JoinHint {
+	return JoinHint{Join: join, Key: "JOIN_METHOD", Value: method}
+}
+
+// ForceJoinOrder returns a join hint that instructs Cloud Spanner to use the
+// join order of the query for the given join.
+func ForceJoinOrder(join string, enabled bool) JoinHint {
+	return JoinHint{Join: join, Key: "FORCE_JOIN_ORDER", Value: boolHintValue(enabled)}
+}
+
+// HashJoinBuildSide returns a join hint that sets the build side, BUILD_LEFT
+// or BUILD_RIGHT, of a hash join.
+func HashJoinBuildSide(join, side string) JoinHint {
+	return JoinHint{Join: join, Key: "HASH_JOIN_BUILD_SIDE", Value: side}
+}
+
+// BatchMode returns a join hint that enables or disables batch mode for an
+// apply join.
+func BatchMode(join string, enabled bool) JoinHint {
+	return JoinHint{Join: join, Key: "BATCH_MODE", Value: boolHintValue(enabled)}
+}
+
+// JoinTableHint is a Cloud Spanner table hint for the joined table of a join
+// that is added with Joins. The hint is added after the joined table.
+type JoinTableHint struct {
+	// Join is the name of the association that is joined, as passed to Joins,
+	// e.g. "Albums" or "Singer.Albums", or the name of the joined table.
+	Join string
+	// Hint is a TableHint or an IndexHint.
+	Hint hint
+}
+
+func (joinTableHint JoinTableHint) ModifyStatement(stmt *gorm.Statement) {
+	addHint(stmt, joinTableHintsPrefix+joinTableHint.Join, joinTableHint.Hint)
+}
+
+func (joinTableHint JoinTableHint) Build(builder clause.Builder) {
+	hintExpression{joinTableHint.Hint}.Build(builder)
+}
+
+// ForceJoinIndex returns an index hint that forces Cloud Spanner to use the
+// given index for the joined table of the given join.
+func ForceJoinIndex(join, index string) JoinTableHint {
+	return JoinTableHint{Join: join, Hint: ForceIndex(index)}
+}
+
+// buildFrom builds the FROM clause of a query. Table hints are added directly
+// after the tables in the FROM clause, and join hints and the table hints of
+// joined tables are added to the joins. All other parts of the clause are
+// built in the same way as by the default builder.
+func buildFrom(c clause.Clause, builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok {
+		c.Build(builder)
+		return
+	}
+	used := make(map[string]bool)
+	defer checkJoinHints(stmt, used)
+	from, isFrom := c.Expression.(clause.From)
+	if !isFrom || len(from.Joins) == 0 {
+		c.Build(builder)
+		return
+	}
+	tableHints, afterExpression := splitTableHints(c.AfterExpression)
+	if c.BeforeExpression != nil {
+		c.BeforeExpression.Build(builder)
+		builder.WriteByte(' ')
+	}
+	builder.WriteString("FROM ")
+	if c.AfterNameExpression != nil {
+		c.AfterNameExpression.Build(builder)
+		builder.WriteByte(' ')
+	}
+	if len(from.Tables) > 0 {
+		for idx, table := range from.Tables {
+			if idx > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteQuoted(table)
+		}
+	} else {
+		builder.WriteQuoted(clause.Table{Name: clause.CurrentTable})
+	}
+	if tableHints != nil {
+		builder.WriteByte(' ')
+		tableHints.Build(builder)
+	}
+	for _, join := range from.Joins {
+		builder.WriteByte(' ')
+		buildJoin(stmt, join, used)
+	}
+	if afterExpression != nil {
+		builder.WriteByte(' ')
+		afterExpression.Build(builder)
+	}
+}
+
+// splitTableHints splits the AfterExpression of a FROM clause into the table
+// hints that are added by addTableHint and any other expressions.
+func splitTableHints(expr clause.Expression) (hintExpression, clause.Expression) {
+	switch e := expr.(type) {
+	case hintExpression:
+		return e, nil
+	case Exprs:
+		if hints, ok := e[len(e)-1].(hintExpression); ok {
+			if len(e) == 1 {
+				return hints, nil
+			}
+			return hints, e[:len(e)-1]
+		}
+	}
+	return nil, expr
+}
+
+// checkJoinHints adds an error to the statement if it contains join hints
+// that have not been used for any of the joins of the statement.
+func checkJoinHints(stmt *gorm.Statement, used map[string]bool) {
+	var unused []string
+	for name := range stmt.Clauses {
+		if (strings.HasPrefix(name, joinHintsClausePrefix) || strings.HasPrefix(name, joinTableHintsPrefix)) && !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) == 0 {
+		return
+	}
+	sort.Strings(unused)
+	join := strings.TrimPrefix(strings.TrimPrefix(unused[0], joinHintsClausePrefix), joinTableHintsPrefix)
+	_ = stmt.AddError(fmt.Errorf("spanner: the hints for join %s cannot be applied, as the query has no join for an association or table with that name; "+
+		"hints are not supported for joins that are specified as an SQL string or for preloaded associations", join))
+}
+
+// buildJoin builds a join with the join hints and table hints for the join.
+// Joins that are specified as an SQL string are added as-is.
+func buildJoin(stmt *gorm.Statement, join clause.Join, used map[string]bool) {
+	if join.Expression != nil {
+		join.Build(stmt)
+		return
+	}
+	if join.Type != "" {
+		stmt.WriteString(string(join.Type))
+		stmt.WriteByte(' ')
+	}
+	stmt.WriteString("JOIN ")
+	if hints := joinHintsOf(stmt, joinHintsClausePrefix, join.Table, used); len(hints) > 0 {
+		hints.Build(stmt)
+		stmt.WriteByte(' ')
+	}
+	stmt.WriteQuoted(clause.Table{Name: join.Table.Name, Raw: join.Table.Raw})
+	if hints := joinHintsOf(stmt, joinTableHintsPrefix, join.Table, used); len(hints) > 0 {
+		stmt.WriteByte(' ')
+		hints.Build(stmt)
+	}
+	if join.Table.Alias != "" {
+		stmt.WriteByte(' ')
+		stmt.WriteQuoted(clause.Table{Name: join.Table.Alias, Raw: join.Table.Raw})
+	}
+	if len(join.ON.Exprs) > 0 {
+		stmt.WriteString(" ON ")
+		join.ON.Build(stmt)
+	} else if len(join.Using) > 0 {
+		stmt.WriteString(" USING (")
+		for idx, column := range join.Using {
+			if idx > 0 {
+				stmt.WriteByte(',')
+			}
+			stmt.WriteQuoted(column)
+		}
+		stmt.WriteByte(')')
+	}
+}
+
+// joinHintsOf returns the hints with the given prefix for the joined table,
+// and marks the hints as used. The hints are looked up by the association name
+// of the join, which gorm uses as the alias of the joined table, and otherwise
+// by the table name.
+func joinHintsOf(stmt *gorm.Statement, prefix string, table clause.Table, used map[string]bool) hintExpression {
+	if table.Alias != "" {
+		// gorm uses Parent__Child as the alias for nested joins.
+		name := prefix + strings.ReplaceAll(table.Alias, "__", ".")
+		if hints := hintsOf(stmt, name); len(hints) > 0 {
+			used[name] = true
+			return hints
+		}
+	}
+	name := prefix + table.Name
+	used[name] = true
+	return hintsOf(stmt, name)
+}
+
+func boolHintValue(value bool) string {
+	return strings.ToUpper(strconv.FormatBool(value))
+}
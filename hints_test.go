@@ -0,0 +1,213 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestHints(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+
+	for _, test := range []struct {
+		name string
+		exec func(tx *gorm.DB) *gorm.DB
+		want string
+	}{
+		{
+			name: "force index",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(ForceIndex("idx_singers_name")).Find(&[]singer{})
+			},
+			want: "SELECT * FROM `singers` @{FORCE_INDEX=`idx_singers_name`} WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name: "table hints",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(ForceIndex("idx_singers_name"), ScanMethod("BATCH"), GroupByScanOptimization(true), IndexStrategy("FORCE_INDEX_UNION")).Find(&[]singer{})
+			},
+			want: "SELECT * FROM `singers` @{FORCE_INDEX=`idx_singers_name`,SCAN_METHOD=BATCH,GROUPBY_SCAN_OPTIMIZATION=TRUE,INDEX_STRATEGY=FORCE_INDEX_UNION} WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name: "statement hints",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(UseAdditionalParallelism(true), OptimizerVersion("6"), OptimizerStatisticsPackage("latest"), AllowDistributedMerge(false)).Find(&[]singer{})
+			},
+			want: "@{USE_ADDITIONAL_PARALLELISM=TRUE,OPTIMIZER_VERSION=6,OPTIMIZER_STATISTICS_PACKAGE=latest,ALLOW_DISTRIBUTED_MERGE=FALSE} SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name: "statement and table hints",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(OptimizerVersion("latest_version"), ScanMethod("ROW"), clause.Locking{Strength: "UPDATE"}).Find(&[]singer{})
+			},
+			want: "@{OPTIMIZER_VERSION=latest_version,LOCK_SCANNED_RANGES=exclusive} SELECT * FROM `singers` @{SCAN_METHOD=ROW} WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name: "replace hint",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(OptimizerVersion("5")).Clauses(OptimizerVersion("6")).Find(&[]singer{})
+			},
+			want: "@{OPTIMIZER_VERSION=6} SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name: "update",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(OptimizerVersion("6")).Model(&singer{Model: gorm.Model{ID: 1}}).Update("first_name", "First")
+			},
+			want: "@{OPTIMIZER_VERSION=6} UPDATE `singers` SET `first_name`=?,`updated_at`=? WHERE `singers`.`deleted_at` IS NULL AND `id` = ?",
+		},
+		{
+			name: "delete",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(OptimizerVersion("6")).Unscoped().Delete(&singer{Model: gorm.Model{ID: 1}})
+			},
+			want: "@{OPTIMIZER_VERSION=6} DELETE FROM `singers` WHERE `singers`.`id` = ?",
+		},
+	} {
+		res := test.exec(dryRun)
+		if res.Error != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, res.Error)
+			continue
+		}
+		if g, w := res.Statement.SQL.String(), test.want; g != w {
+			t.Errorf("%s: sql mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
+
+func TestJoinHints(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+
+	const selectAlbumsAndSingers = "SELECT `albums`.`id`,`albums`.`created_at`,`albums`.`updated_at`,`albums`.`deleted_at`,`albums`.`title`,`albums`.`singer_id`," +
+		"`Singer`.`id` AS `Singer__id`,`Singer`.`created_at` AS `Singer__created_at`,`Singer`.`updated_at` AS `Singer__updated_at`,`Singer`.`deleted_at` AS `Singer__deleted_at`," +
+		"`Singer`.`first_name` AS `Singer__first_name`,`Singer`.`last_name` AS `Singer__last_name`,`Singer`.`full_name` AS `Singer__full_name`,`Singer`.`active` AS `Singer__active` "
+	for _, test := range []struct {
+		name string
+		exec func(tx *gorm.DB) *gorm.DB
+		want string
+	}{
+		{
+			name: "join hints",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(JoinMethod("Singer", "HASH_JOIN"), HashJoinBuildSide("Singer", "BUILD_RIGHT")).Joins("Singer").Find(&[]album{})
+			},
+			want: selectAlbumsAndSingers + "FROM `albums` LEFT JOIN @{JOIN_METHOD=HASH_JOIN,HASH_JOIN_BUILD_SIDE=BUILD_RIGHT} `singers` `Singer` ON `albums`.`singer_id` = `Singer`.`id` AND `Singer`.`deleted_at` IS NULL WHERE `albums`.`deleted_at` IS NULL",
+		},
+		{
+			name: "join hints by table name",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(ForceJoinOrder("singers", true), BatchMode("singers", false)).Joins("Singer").Find(&[]album{})
+			},
+			want: selectAlbumsAndSingers + "FROM `albums` LEFT JOIN @{FORCE_JOIN_ORDER=TRUE,BATCH_MODE=FALSE} `singers` `Singer` ON `albums`.`singer_id` = `Singer`.`id` AND `Singer`.`deleted_at` IS NULL WHERE `albums`.`deleted_at` IS NULL",
+		},
+		{
+			name: "table hints",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(ForceIndex("idx_albums_title"), ForceJoinIndex("Singer", "idx_singers_name")).Joins("Singer").Find(&[]album{})
+			},
+			want: selectAlbumsAndSingers + "FROM `albums` @{FORCE_INDEX=`idx_albums_title`} LEFT JOIN `singers` @{FORCE_INDEX=`idx_singers_name`} `Singer` ON `albums`.`singer_id` = `Singer`.`id` AND `Singer`.`deleted_at` IS NULL WHERE `albums`.`deleted_at` IS NULL",
+		},
+		{
+			name: "sql join",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(ForceIndex("idx_albums_title")).Joins("JOIN singers ON singers.id = albums.singer_id").Find(&[]album{})
+			},
+			want: "SELECT `albums`.`id`,`albums`.`created_at`,`albums`.`updated_at`,`albums`.`deleted_at`,`albums`.`title`,`albums`.`singer_id` FROM `albums` @{FORCE_INDEX=`idx_albums_title`} JOIN singers ON singers.id = albums.singer_id WHERE `albums`.`deleted_at` IS NULL",
+		},
+		{
+			name: "clause expressions",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(fromExpressions{}, ForceIndex("idx_albums_title")).Joins("Singer").Find(&[]album{})
+			},
+			want: selectAlbumsAndSingers + "/* before */ FROM /* after name */ `albums` @{FORCE_INDEX=`idx_albums_title`} LEFT JOIN `singers` `Singer` ON `albums`.`singer_id` = `Singer`.`id` AND `Singer`.`deleted_at` IS NULL /* after */ WHERE `albums`.`deleted_at` IS NULL",
+		},
+	} {
+		res := test.exec(dryRun)
+		if res.Error != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, res.Error)
+			continue
+		}
+		if g, w := res.Statement.SQL.String(), test.want; g != w {
+			t.Errorf("%s: sql mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
+
+// fromExpressions adds expressions before, after the name of, and after the
+// FROM clause.
+type fromExpressions struct{}
+
+func (fromExpressions) ModifyStatement(stmt *gorm.Statement) {
+	c := stmt.Clauses["FROM"]
+	c.BeforeExpression = clause.Expr{SQL: "/* before */"}
+	c.AfterNameExpression = clause.Expr{SQL: "/* after name */"}
+	c.AfterExpression = clause.Expr{SQL: "/* after */"}
+	stmt.Clauses["FROM"] = c
+}
+
+func (fromExpressions) Build(clause.Builder) {}
+
+func TestJoinHintsWithoutJoin(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+
+	for _, test := range []struct {
+		name string
+		exec func(tx *gorm.DB) *gorm.DB
+	}{
+		{
+			name: "sql join",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(JoinMethod("singers", "HASH_JOIN")).Joins("JOIN singers ON singers.id = albums.singer_id").Find(&[]album{})
+			},
+		},
+		{
+			name: "sql join table hint",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(ForceJoinIndex("singers", "idx_singers_name")).Joins("JOIN singers ON singers.id = albums.singer_id").Find(&[]album{})
+			},
+		},
+		{
+			name: "preload",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(JoinMethod("Singer", "HASH_JOIN")).Preload("Singer").Find(&[]album{})
+			},
+		},
+		{
+			name: "unknown join",
+			exec: func(tx *gorm.DB) *gorm.DB {
+				return tx.Clauses(JoinMethod("Albums", "HASH_JOIN")).Joins("Singer").Find(&[]album{})
+			},
+		},
+	} {
+		if err := test.exec(dryRun).Error; err == nil {
+			t.Errorf("%s: missing expected error", test.name)
+		}
+	}
+}
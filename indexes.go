@@ -0,0 +1,193 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Indexes support the following Cloud Spanner specific options in the index
+// tag of a field, in addition to the standard gorm index options:
+//
+//   - storing:col1|col2 adds a STORING clause with the given columns.
+//   - null_filtered creates a NULL_FILTERED index.
+//   - interleave:Parent interleaves the index in the given table.
+//   - desc sorts the column of the field in descending order. This is the same
+//     as sort:desc.
+//
+// The options storing, null_filtered and interleave apply to the whole index,
+// and can be set on any of the fields of the index.
+//
+// An interleaved index must be created on a table that is interleaved in the
+// same parent, and the index must start with the primary key columns of the
+// parent table.
+//
+// Example:
+//
+//	type Track struct {
+//		ID          int64  `gorm:"primaryKey;autoIncrement:false;index:idx_tracks_title,interleave:albums,storing:duration"`
+//		TrackNumber int64  `gorm:"primaryKey;autoIncrement:false"`
+//		Title       string `gorm:"index:idx_tracks_title,null_filtered,desc"`
+//		Duration    int64
+//	}
+//
+//	func (Track) InterleaveInParent() (interface{}, string) {
+//		return &Album{}, InterleaveOnDeleteCascade
+//	}
+
+// indexOptions contains the Cloud Spanner specific options of an index.
+type indexOptions struct {
+	storing      []string
+	nullFiltered bool
+	interleave   string
+	// descending contains the names of the fields that are sorted in
+	// descending order.
+	descending map[string]bool
+}
+
+// parseIndexOptions parses the Cloud Spanner specific options of the index
+// from the tags of the fields of the index.
+func (m spannerMigrator) parseIndexOptions(s *schema.Schema, idx *schema.Index) indexOptions {
+	opts := indexOptions{descending: map[string]bool{}}
+	for _, option := range idx.Fields {
+		for _, value := range strings.Split(option.Tag.Get("gorm"), ";") {
+			v := strings.Split(value, ":")
+			k := strings.TrimSpace(strings.ToUpper(v[0]))
+			if k != "INDEX" && k != "UNIQUEINDEX" {
+				continue
+			}
+			var (
+				tag      = strings.Join(v[1:], ":")
+				parts    = strings.Split(tag, ",")
+				settings = schema.ParseTagSetting(strings.Join(parts[1:], ","), ",")
+				name     = parts[0]
+			)
+			if name == "" {
+				subName := option.Name
+				if composite := settings["COMPOSITE"]; composite != "" {
+					subName = composite
+				}
+				name = m.DB.NamingStrategy.IndexName(s.Table, subName)
+			}
+			if name != idx.Name {
+				continue
+			}
+			if storing := settings["STORING"]; storing != "" {
+				for _, column := range strings.Split(storing, "|") {
+					if field := s.LookUpField(column); field != nil {
+						column = field.DBName
+					}
+					opts.storing = append(opts.storing, column)
+				}
+			}
+			if _, ok := settings["NULL_FILTERED"]; ok {
+				opts.nullFiltered = true
+			}
+			if interleave := settings["INTERLEAVE"]; interleave != "" {
+				opts.interleave = interleave
+			}
+			if _, ok := settings["DESC"]; ok {
+				opts.descending[option.Name] = true
+			}
+		}
+	}
+	return opts
+}
+
+// BuildIndexOptions builds the columns of an index. Cloud Spanner only
+// supports a sort order for the columns of an index.
+func (m spannerMigrator) BuildIndexOptions(opts []schema.IndexOption, stmt *gorm.Statement) (results []interface{}) {
+	for _, opt := range opts {
+		str := stmt.Quote(opt.DBName)
+		if opt.Expression != "" {
+			str = opt.Expression
+		}
+		if opt.Sort != "" {
+			str += " " + strings.ToUpper(opt.Sort)
+		}
+		results = append(results, clause.Expr{SQL: str})
+	}
+	return
+}
+
+// CreateIndex creates the index with the given name, including the Cloud
+// Spanner specific options of the index.
+func (m spannerMigrator) CreateIndex(value interface{}, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		idx := stmt.Schema.LookIndex(name)
+		if idx == nil {
+			return fmt.Errorf("failed to create index with name %s", name)
+		}
+		if idx.Type != "" || idx.Where != "" {
+			return fmt.Errorf("spanner: index %s uses a type or a WHERE clause, which is not supported by Cloud Spanner", idx.Name)
+		}
+		opts := m.parseIndexOptions(stmt.Schema, idx)
+		fields := make([]schema.IndexOption, len(idx.Fields))
+		for i, field := range idx.Fields {
+			fields[i] = field
+			if opts.descending[field.Name] {
+				fields[i].Sort = "DESC"
+			}
+		}
+
+		createIndexSQL := "CREATE "
+		if idx.Class != "" {
+			createIndexSQL += idx.Class + " "
+		}
+		if opts.nullFiltered {
+			createIndexSQL += "NULL_FILTERED "
+		}
+		createIndexSQL += "INDEX ? ON ??"
+		values := []interface{}{clause.Column{Name: idx.Name}, m.CurrentTable(stmt), m.BuildIndexOptions(fields, stmt)}
+		if len(opts.storing) > 0 {
+			storing := make([]interface{}, len(opts.storing))
+			for i, column := range opts.storing {
+				storing[i] = clause.Column{Name: column}
+			}
+			createIndexSQL += " STORING ?"
+			values = append(values, storing)
+		}
+		if opts.interleave != "" {
+			createIndexSQL += ", INTERLEAVE IN ?"
+			values = append(values, clause.Table{Name: opts.interleave})
+		}
+		return m.DB.Exec(createIndexSQL, values...).Error
+	})
+}
+
+// HasIndex returns true if the table of the given model has an index with the
+// given name. Only the name of the index is compared with the
+// INFORMATION_SCHEMA of the database. AutoMigrate therefore does not change the
+// columns, sort order or options of an existing index. An index whose
+// definition has changed must be dropped and recreated manually with DropIndex
+// and CreateIndex.
+func (m spannerMigrator) HasIndex(value interface{}, name string) bool {
+	var count int64
+	_ = m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if idx := stmt.Schema.LookIndex(name); idx != nil {
+			name = idx.Name
+		}
+		return m.DB.Raw(
+			"SELECT count(*) FROM information_schema.indexes WHERE table_schema = '' AND table_name = ? AND index_name = ?",
+			stmt.Table, name,
+		).Row().Scan(&count)
+	})
+	return count > 0
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// InterleaveOnDeleteCascade deletes the rows of an interleaved table when
+	// the parent row is deleted.
+	InterleaveOnDeleteCascade = "CASCADE"
+	// InterleaveOnDeleteNoAction prevents the deletion of a parent row that
+	// has rows in an interleaved table.
+	InterleaveOnDeleteNoAction = "NO ACTION"
+)
+
+// InterleavedModel is implemented by models whose table is interleaved in the
+// table of a parent model. The migrator creates the table of an
+// InterleavedModel with an INTERLEAVE IN PARENT clause, and creates the
+// parent table before the interleaved table. The primary key of an
+// interleaved table must start with the primary key columns of the parent
+// table.
+//
+// Example:
+//
+//	type Track struct {
+//		AlbumID     int64 `gorm:"primaryKey;autoIncrement:false"`
+//		TrackNumber int64 `gorm:"primaryKey;autoIncrement:false"`
+//		Title       string
+//	}
+//
+//	func (Track) InterleaveInParent() (interface{}, string) {
+//		return &Album{}, spannergorm.InterleaveOnDeleteCascade
+//	}
+type InterleavedModel interface {
+	// InterleaveInParent returns a model of the parent table and the ON
+	// DELETE action of the table. The action must be either
+	// InterleaveOnDeleteCascade, InterleaveOnDeleteNoAction, or an empty
+	// string for the default action of Cloud Spanner, which is NO ACTION.
+	InterleaveInParent() (parent interface{}, onDelete string)
+}
+
+func interleavedModel(s *schema.Schema) (InterleavedModel, bool) {
+	model, ok := reflect.New(s.ModelType).Interface().(InterleavedModel)
+	return model, ok
+}
+
+// parseModel parses the schema of the given model.
+func (m spannerMigrator) parseModel(value interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: m.DB}
+	if err := stmt.Parse(value); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// interleaveClause returns the INTERLEAVE IN PARENT clause of the table of the
+// given schema, or an empty string if the table is not interleaved.
+func (m spannerMigrator) interleaveClause(s *schema.Schema) (string, []interface{}, error) {
+	model, ok := interleavedModel(s)
+	if !ok {
+		return "", nil, nil
+	}
+	parentModel, onDelete := model.InterleaveInParent()
+	parent, err := m.parseModel(parentModel)
+	if err != nil {
+		return "", nil, fmt.Errorf("spanner: failed to parse the parent of table %s: %w", s.Table, err)
+	}
+	if len(s.PrimaryFields) < len(parent.PrimaryFields) {
+		return "", nil, fmt.Errorf("spanner: the primary key of table %s must start with the primary key columns of parent table %s", s.Table, parent.Table)
+	}
+	for i, parentField := range parent.PrimaryFields {
+		field := s.PrimaryFields[i]
+		if field.DBName != parentField.DBName || !strings.EqualFold(m.Migrator.DataTypeOf(field), m.Migrator.DataTypeOf(parentField)) {
+			return "", nil, fmt.Errorf("spanner: the primary key of table %s must start with the primary key columns of parent table %s, but column %d is %s instead of %s",
+				s.Table, parent.Table, i+1, field.DBName, parentField.DBName)
+		}
+	}
+	sql := ", INTERLEAVE IN PARENT ?"
+	switch action := strings.ToUpper(onDelete); action {
+	case "":
+	case InterleaveOnDeleteCascade, InterleaveOnDeleteNoAction:
+		sql += " ON DELETE " + action
+	default:
+		return "", nil, fmt.Errorf("spanner: invalid ON DELETE action %q for interleaved table %s", onDelete, s.Table)
+	}
+	return sql, []interface{}{clause.Table{Name: parent.Table}}, nil
+}
+
+// orderInterleavedModels orders the given models so the parent of an
+// interleaved table comes before the interleaved table. The order of all other
+// models is not changed. The parents of interleaved tables that are not in
+// values are added if autoAdd is true.
+func (m spannerMigrator) orderInterleavedModels(values []interface{}, autoAdd bool) []interface{} {
+	var (
+		ordered = make([]interface{}, 0, len(values))
+		tables  = map[string]interface{}{}
+		added   = map[string]bool{}
+		add     func(value interface{})
+	)
+	for _, value := range values {
+		if s, err := m.parseModel(value); err == nil {
+			tables[s.Table] = value
+		}
+	}
+	add = func(value interface{}) {
+		s, err := m.parseModel(value)
+		if err != nil {
+			// Table names and models that cannot be parsed are kept in their
+			// original position.
+			ordered = append(ordered, value)
+			return
+		}
+		if added[s.Table] {
+			return
+		}
+		added[s.Table] = true
+		if model, ok := interleavedModel(s); ok {
+			parentModel, _ := model.InterleaveInParent()
+			if parent, err := m.parseModel(parentModel); err == nil {
+				if parentValue, ok := tables[parent.Table]; ok {
+					add(parentValue)
+				} else if autoAdd {
+					add(parentModel)
+				}
+			}
+		}
+		ordered = append(ordered, value)
+	}
+	for _, value := range values {
+		add(value)
+	}
+	return ordered
+}
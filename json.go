@@ -0,0 +1,321 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Fields of type spanner.NullJSON are stored in JSON columns. Fields with the
+// tag `gorm:"serializer:spanner_json"` are serialized to JSON and are also
+// stored in JSON columns. The serialized value of these fields is bound as a
+// spanner.NullJSON, as Cloud Spanner does not accept a STRING value for a
+// JSON column. Fields with the standard tag `gorm:"serializer:json;type:json"`
+// are also bound as a spanner.NullJSON.
+//
+// Example:
+//
+//	type Venue struct {
+//		ID          int64
+//		Description spanner.NullJSON
+//		Address     Address `gorm:"serializer:spanner_json"`
+//		Tags        []string `gorm:"serializer:json;type:json"`
+//	}
+
+// jsonSerializerName is the name of the serializer for fields that are
+// stored in JSON columns.
+const jsonSerializerName = "spanner_json"
+
+// jsonSerializer serializes a field to a spanner.NullJSON, and deserializes
+// the JSON values that are read from the database into the field.
+type jsonSerializer struct{}
+
+// Scan implements schema.SerializerInterface.
+func (jsonSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) (err error) {
+	var b []byte
+	switch v := dbValue.(type) {
+	case nil:
+	case spanner.NullJSON:
+		if v.Valid {
+			if b, err = jsonBytes(v.Value); err != nil {
+				return err
+			}
+		}
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("spanner: cannot scan a value of type %T into JSON field %s", dbValue, field.Name)
+	}
+	fieldValue := reflect.New(field.FieldType)
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, fieldValue.Interface()); err != nil {
+			return err
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// jsonBytes returns the JSON encoding of the value of a spanner.NullJSON.
+// Values that are already encoded are returned as is, so the numbers in these
+// values keep their precision. Note that the Cloud Spanner client decodes the
+// JSON values that are read from the database into an interface{}, which
+// converts all numbers to float64.
+func jsonBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case json.RawMessage:
+		return v, nil
+	case *json.RawMessage:
+		return *v, nil
+	}
+	return json.Marshal(value)
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (jsonSerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	b, err := json.Marshal(fieldValue)
+	if err != nil {
+		return nil, err
+	}
+	// A nil value is stored as NULL, unless the column is NOT NULL, in which
+	// case it is stored as a JSON null.
+	if string(b) == "null" && field.TagSettings["NOT NULL"] == "" {
+		return spanner.NullJSON{}, nil
+	}
+	return spanner.NullJSON{Value: json.RawMessage(b), Valid: true}, nil
+}
+
+// standardJSONSerializer replaces the json serializer of gorm. It scans the
+// spanner.NullJSON values that are returned for JSON columns, and otherwise
+// works exactly like schema.JSONSerializer, so the serializer can still be
+// used with other databases.
+type standardJSONSerializer struct {
+	schema.JSONSerializer
+}
+
+// Scan implements schema.SerializerInterface.
+func (s standardJSONSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	if v, ok := dbValue.(spanner.NullJSON); ok {
+		dbValue = nil
+		if v.Valid {
+			b, err := jsonBytes(v.Value)
+			if err != nil {
+				return err
+			}
+			dbValue = b
+		}
+	}
+	return s.JSONSerializer.Scan(ctx, field, dst, dbValue)
+}
+
+// isStandardJSONField returns true if the field uses the json serializer of
+// gorm and is stored in a JSON column.
+func isStandardJSONField(field *schema.Field) bool {
+	_, ok := field.Serializer.(standardJSONSerializer)
+	return ok && strings.EqualFold(string(field.DataType), "JSON")
+}
+
+// hasStandardJSONFields returns true if the schema contains a field that uses
+// the json serializer of gorm and that is stored in a JSON column.
+func hasStandardJSONFields(s *schema.Schema) bool {
+	if s == nil {
+		return false
+	}
+	for _, field := range s.Fields {
+		if isStandardJSONField(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldValue returns the value that is bound for the given column. The
+// json serializer of gorm returns a string, which is wrapped in a jsonValue
+// for columns of type JSON.
+func jsonFieldValue(s *schema.Schema, column string, value interface{}) interface{} {
+	if s == nil {
+		return value
+	}
+	if field := s.LookUpField(column); field != nil && isStandardJSONField(field) {
+		if valuer, ok := value.(driver.Valuer); ok {
+			return jsonValue{valuer: valuer}
+		}
+	}
+	return value
+}
+
+// jsonValue binds the string that is returned by the json serializer of gorm
+// as a spanner.NullJSON.
+type jsonValue struct {
+	valuer driver.Valuer
+}
+
+// Value implements driver.Valuer.
+func (v jsonValue) Value() (driver.Value, error) {
+	value, err := v.valuer.Value()
+	if err != nil {
+		return nil, err
+	}
+	switch s := value.(type) {
+	case nil:
+		return spanner.NullJSON{}, nil
+	case string:
+		// The serializer returns an empty string for a nil value of a
+		// NOT NULL field, which is stored as a JSON null.
+		if s == "" {
+			s = "null"
+		}
+		return spanner.NullJSON{Value: json.RawMessage(s), Valid: true}, nil
+	case []byte:
+		return spanner.NullJSON{Value: json.RawMessage(s), Valid: true}, nil
+	}
+	return value, nil
+}
+
+// jsonLiteral returns the JSON literal for the given value if it is a JSON
+// value.
+func jsonLiteral(v interface{}) (string, bool) {
+	switch j := v.(type) {
+	case spanner.NullJSON:
+		if !j.Valid {
+			return "NULL", true
+		}
+		return "JSON " + stringLiteral(j.String()), true
+	case *spanner.NullJSON:
+		if j == nil {
+			return "NULL", true
+		}
+		return jsonLiteral(*j)
+	}
+	return "", false
+}
+
+// stringLiteral returns s as a Cloud Spanner string literal.
+func stringLiteral(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// jsonFunction returns the SQL of a call to a JSON function with a column and
+// a JSONPath. The JSONPath must be a string literal.
+func jsonFunction(name, column, path string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('(')
+	Dialector{}.QuoteTo(&b, column)
+	b.WriteString(", ")
+	b.WriteString(stringLiteral(path))
+	b.WriteByte(')')
+	return b.String()
+}
+
+// JSONValueExpression is a JSON_VALUE expression that extracts a scalar value
+// from a JSON column. It can be used as a condition in Where, and for ordering
+// the results of a query in Order.
+type JSONValueExpression struct {
+	column string
+	path   string
+}
+
+// JSONValue returns a JSON_VALUE expression that extracts the scalar value at
+// the given JSONPath from a JSON column. JSON_VALUE returns the value as a
+// STRING, and the values that the expression is compared with must therefore
+// also be strings.
+//
+// Example:
+//
+//	db.Where(spannergorm.JSONValue("address", "$.city").Eq("Amsterdam")).
+//		Order(spannergorm.JSONValue("address", "$.street").Asc()).
+//		Find(&venues)
+func JSONValue(column, path string) JSONValueExpression {
+	return JSONValueExpression{column: column, path: path}
+}
+
+// Build implements clause.Expression.
+func (e JSONValueExpression) Build(builder clause.Builder) {
+	builder.WriteString(jsonFunction("JSON_VALUE", e.column, e.path))
+}
+
+// Eq returns a condition that checks whether the value is equal to value, or
+// whether the value is NULL if value is nil.
+func (e JSONValueExpression) Eq(value interface{}) clause.Expression {
+	if value == nil {
+		return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{e}}
+	}
+	return clause.Expr{SQL: "? = ?", Vars: []interface{}{e, value}}
+}
+
+// In returns a condition that checks whether the value is one of values.
+func (e JSONValueExpression) In(values ...interface{}) clause.Expression {
+	if len(values) == 0 {
+		return clause.Expr{SQL: "? IN (NULL)", Vars: []interface{}{e}}
+	}
+	return clause.Expr{SQL: "? IN ?", Vars: []interface{}{e, values}}
+}
+
+// Asc returns an ascending ORDER BY column for the value.
+func (e JSONValueExpression) Asc() clause.OrderByColumn {
+	return clause.OrderByColumn{Column: clause.Column{Name: jsonFunction("JSON_VALUE", e.column, e.path), Raw: true}}
+}
+
+// Desc returns a descending ORDER BY column for the value.
+func (e JSONValueExpression) Desc() clause.OrderByColumn {
+	return clause.OrderByColumn{Column: clause.Column{Name: jsonFunction("JSON_VALUE", e.column, e.path), Raw: true}, Desc: true}
+}
+
+// JSONQueryExpression is a JSON_QUERY expression that extracts a JSON value
+// from a JSON column.
+type JSONQueryExpression struct {
+	column string
+	path   string
+}
+
+// JSONQuery returns a JSON_QUERY expression that extracts the JSON value at
+// the given JSONPath from a JSON column. The expression can be selected as a
+// column, and can be used to check whether a JSONPath exists in a document.
+//
+// Example:
+//
+//	db.Where(spannergorm.JSONQuery("address", "$.coordinates").Exists()).Find(&venues)
+func JSONQuery(column, path string) JSONQueryExpression {
+	return JSONQueryExpression{column: column, path: path}
+}
+
+// Build implements clause.Expression.
+func (e JSONQueryExpression) Build(builder clause.Builder) {
+	builder.WriteString(jsonFunction("JSON_QUERY", e.column, e.path))
+}
+
+// Exists returns a condition that checks whether the JSONPath exists in the
+// document.
+func (e JSONQueryExpression) Exists() clause.Expression {
+	return clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{e}}
+}
+
+// NotExists returns a condition that checks whether the JSONPath does not
+// exist in the document.
+func (e JSONQueryExpression) NotExists() clause.Expression {
+	return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{e}}
+}
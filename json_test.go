@@ -0,0 +1,233 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"gorm.io/gorm"
+)
+
+type address struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+type venue struct {
+	ID          int64 `gorm:"primarykey;autoIncrement:false"`
+	Description spanner.NullJSON
+	Address     address `gorm:"serializer:spanner_json"`
+}
+
+func TestJSONFields(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	v := venue{
+		ID:          1,
+		Description: spanner.NullJSON{Value: map[string]interface{}{"capacity": 100}, Valid: true},
+		Address:     address{Street: "Main Street", City: "Amsterdam"},
+	}
+	res := db.Session(&gorm.Session{DryRun: true}).Create(&v)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	value, err := res.Statement.Vars[2].(driver.Valuer).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := value.(spanner.NullJSON).String(), `{"street":"Main Street","city":"Amsterdam"}`; g != w {
+		t.Fatalf("address mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := db.Dialector.Explain(res.Statement.SQL.String(), res.Statement.Vars...),
+		"INSERT INTO `venues` (`id`,`description`,`address`) VALUES (1,JSON '{\"capacity\":100}',JSON '{\"street\":\"Main Street\",\"city\":\"Amsterdam\"}')"; g != w {
+		t.Fatalf("explain mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	field := res.Statement.Schema.LookUpField("Address")
+	scanner := field.NewValuePool.Get()
+	if err := scanner.(sql.Scanner).Scan(spanner.NullJSON{
+		Value: map[string]interface{}{"street": "Station Road", "city": "Utrecht"},
+		Valid: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var scanned venue
+	if err := field.Set(context.Background(), reflect.ValueOf(&scanned).Elem(), scanner); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := scanned.Address, (address{Street: "Station Road", City: "Utrecht"}); g != w {
+		t.Fatalf("scanned address mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+type standardJSONVenue struct {
+	ID      int64    `gorm:"primarykey;autoIncrement:false"`
+	Address address  `gorm:"serializer:json;type:json"`
+	Tags    []string `gorm:"serializer:json;type:json"`
+}
+
+func TestStandardJSONSerializer(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	v := standardJSONVenue{
+		ID:      1,
+		Address: address{Street: "Main Street?", City: "Amsterdam"},
+	}
+	res := db.Session(&gorm.Session{DryRun: true}).Create(&v)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	value, err := res.Statement.Vars[1].(driver.Valuer).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := value.(spanner.NullJSON).String(), `{"street":"Main Street?","city":"Amsterdam"}`; g != w {
+		t.Fatalf("address mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := db.Dialector.Explain(res.Statement.SQL.String(), res.Statement.Vars...),
+		"INSERT INTO `standard_json_venues` (`id`,`address`,`tags`) VALUES (1,JSON '{\"street\":\"Main Street?\",\"city\":\"Amsterdam\"}',NULL)"; g != w {
+		t.Fatalf("explain mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	res = db.Session(&gorm.Session{DryRun: true}).Model(&v).Updates(standardJSONVenue{Tags: []string{"jazz"}})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if g, w := db.Dialector.Explain(res.Statement.SQL.String(), res.Statement.Vars...),
+		"UPDATE `standard_json_venues` SET `tags`=JSON '[\"jazz\"]' WHERE `id` = 1"; g != w {
+		t.Fatalf("update explain mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	field := res.Statement.Schema.LookUpField("Tags")
+	scanner := field.NewValuePool.Get()
+	if err := scanner.(sql.Scanner).Scan(spanner.NullJSON{Value: []interface{}{"jazz", "blues"}, Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	var scanned standardJSONVenue
+	if err := field.Set(context.Background(), reflect.ValueOf(&scanned).Elem(), scanner); err != nil {
+		t.Fatal(err)
+	}
+	if g, w := scanned.Tags, []string{"jazz", "blues"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("scanned tags mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	scanner = field.NewValuePool.Get()
+	if err := scanner.(sql.Scanner).Scan(spanner.NullJSON{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := field.Set(context.Background(), reflect.ValueOf(&scanned).Elem(), scanner); err != nil {
+		t.Fatal(err)
+	}
+	if scanned.Tags != nil {
+		t.Fatalf("scanned tags mismatch\n Got: %v\nWant: nil", scanned.Tags)
+	}
+}
+
+func TestExplainQuotedPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		sql  string
+		vars []interface{}
+		want string
+	}{
+		{
+			sql:  "SELECT * FROM `venues` WHERE `name` = 'Where?' AND `id` = ?",
+			vars: []interface{}{1},
+			want: "SELECT * FROM `venues` WHERE `name` = 'Where?' AND `id` = 1",
+		},
+		{
+			sql:  "SELECT `a?` FROM `venues` WHERE `name` = \"It\\\"s?\" AND `id` = ?",
+			vars: []interface{}{1},
+			want: "SELECT `a?` FROM `venues` WHERE `name` = \"It\\\"s?\" AND `id` = 1",
+		},
+		{
+			sql:  "SELECT * FROM `venues` WHERE `description` = ? AND `id` = ?",
+			vars: []interface{}{spanner.NullJSON{Value: "Open?", Valid: true}, 1},
+			want: "SELECT * FROM `venues` WHERE `description` = JSON '\"Open?\"' AND `id` = 1",
+		},
+	} {
+		if g, w := (Dialector{}).Explain(test.sql, test.vars...), test.want; g != w {
+			t.Errorf("explain mismatch\n Got: %v\nWant: %v", g, w)
+		}
+	}
+}
+
+func TestJSONExpressions(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	for _, test := range []struct {
+		name string
+		db   func(tx *gorm.DB) *gorm.DB
+		want string
+	}{
+		{
+			name: "JSONValue Eq",
+			db: func(tx *gorm.DB) *gorm.DB {
+				return tx.Where(JSONValue("address", "$.city").Eq("Amsterdam")).Find(&[]venue{})
+			},
+			want: "SELECT * FROM `venues` WHERE JSON_VALUE(`address`, '$.city') = ?",
+		},
+		{
+			name: "JSONValue Eq nil",
+			db: func(tx *gorm.DB) *gorm.DB {
+				return tx.Where(JSONValue("address", "$.city").Eq(nil)).Find(&[]venue{})
+			},
+			want: "SELECT * FROM `venues` WHERE JSON_VALUE(`address`, '$.city') IS NULL",
+		},
+		{
+			name: "JSONValue In",
+			db: func(tx *gorm.DB) *gorm.DB {
+				return tx.Where(JSONValue("address", "$.city").In("Amsterdam", "Utrecht")).Find(&[]venue{})
+			},
+			want: "SELECT * FROM `venues` WHERE JSON_VALUE(`address`, '$.city') IN (?,?)",
+		},
+		{
+			name: "JSONValue Order",
+			db: func(tx *gorm.DB) *gorm.DB {
+				return tx.Order(JSONValue("address", "$.street").Desc()).Find(&[]venue{})
+			},
+			want: "SELECT * FROM `venues` ORDER BY JSON_VALUE(`address`, '$.street') DESC",
+		},
+		{
+			name: "JSONQuery Exists",
+			db: func(tx *gorm.DB) *gorm.DB {
+				return tx.Where(JSONQuery("description", "$.o'hare").Exists()).Find(&[]venue{})
+			},
+			want: "SELECT * FROM `venues` WHERE JSON_QUERY(`description`, '$.o\\'hare') IS NOT NULL",
+		},
+	} {
+		res := test.db(db.Session(&gorm.Session{DryRun: true}))
+		if res.Error != nil {
+			t.Fatalf("%s: %v", test.name, res.Error)
+		}
+		if g, w := res.Statement.SQL.String(), test.want; g != w {
+			t.Fatalf("%s: sql mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Cloud Spanner does not support FOR UPDATE and FOR SHARE clauses. Instead,
+// clause.Locking{Strength: "UPDATE"} is translated to the statement hint
+// @{LOCK_SCANNED_RANGES=exclusive}, which instructs Cloud Spanner to take
+// exclusive locks on the rows that are read by the query.
+// clause.Locking{Strength: "SHARE"} is removed from the statement, as Cloud
+// Spanner takes shared locks for reads in read/write transactions by default.
+// Other lock strengths, locking options and locking clauses for a specific
+// table are not supported.
+
+// lockScannedRangesExclusive is the statement hint that is used for
+// clause.Locking{Strength: "UPDATE"}.
+var lockScannedRangesExclusive = StatementHint{Key: "LOCK_SCANNED_RANGES", Value: "exclusive"}
+
+// translateLocking is a callback that translates the locking clause of a query
+// to a Cloud Spanner statement hint. The hint is added to the statement by the
+// statement hints callback.
+func translateLocking(db *gorm.DB) {
+	c, ok := db.Statement.Clauses[clause.Locking{}.Name()]
+	if !ok {
+		return
+	}
+	delete(db.Statement.Clauses, clause.Locking{}.Name())
+	locking, ok := c.Expression.(clause.Locking)
+	if !ok {
+		_ = db.AddError(fmt.Errorf("spanner: unsupported locking clause: %v", c.Expression))
+		return
+	}
+	if locking.Options != "" {
+		_ = db.AddError(fmt.Errorf("spanner: locking option %s is not supported", locking.Options))
+		return
+	}
+	if locking.Table.Name != "" {
+		_ = db.AddError(fmt.Errorf("spanner: locking clauses for a specific table are not supported"))
+		return
+	}
+	switch strings.ToUpper(locking.Strength) {
+	case "UPDATE":
+		addStatementHint(db.Statement, lockScannedRangesExclusive)
+	case "SHARE":
+		// Cloud Spanner takes shared locks for reads by default.
+	default:
+		_ = db.AddError(fmt.Errorf("spanner: lock strength %s is not supported", locking.Strength))
+	}
+}
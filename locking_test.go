@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestLocking(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+	dryRun := db.Session(&gorm.Session{DryRun: true})
+
+	for _, test := range []struct {
+		name    string
+		locking clause.Locking
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "update",
+			locking: clause.Locking{Strength: "UPDATE"},
+			want:    "@{LOCK_SCANNED_RANGES=exclusive} SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name:    "share",
+			locking: clause.Locking{Strength: "SHARE"},
+			want:    "SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL",
+		},
+		{
+			name:    "nowait",
+			locking: clause.Locking{Strength: "UPDATE", Options: "NOWAIT"},
+			wantErr: true,
+		},
+		{
+			name:    "skip locked",
+			locking: clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"},
+			wantErr: true,
+		},
+		{
+			name:    "table",
+			locking: clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "singers"}},
+			wantErr: true,
+		},
+		{
+			name:    "no key update",
+			locking: clause.Locking{Strength: "NO KEY UPDATE"},
+			wantErr: true,
+		},
+	} {
+		var singers []singer
+		res := dryRun.Clauses(test.locking).Find(&singers)
+		if test.wantErr {
+			if res.Error == nil {
+				t.Errorf("%s: missing expected error", test.name)
+			}
+			continue
+		}
+		if res.Error != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, res.Error)
+			continue
+		}
+		if g, w := res.Statement.SQL.String(), test.want; g != w {
+			t.Errorf("%s: sql mismatch\n Got: %v\nWant: %v", test.name, g, w)
+		}
+	}
+}
@@ -48,7 +48,7 @@ func (m spannerMigrator) AutoMigrate(values ...interface{}) error {
 			return err
 		}
 	}
-	err := m.Migrator.AutoMigrate(values...)
+	err := m.Migrator.AutoMigrate(m.orderInterleavedModels(values, true)...)
 	if err == nil {
 		if m.Dialector.Config.DisableAutoMigrateBatching {
 			return nil
@@ -89,10 +89,14 @@ func (m spannerMigrator) FullDataTypeOf(field *schema.Field) (expr clause.Expr)
 		}
 	}
 
+	if isCommitTimestampField(field) {
+		expr.SQL += " OPTIONS (allow_commit_timestamp=true)"
+	}
+
 	return
 }
 func (m spannerMigrator) CreateTable(values ...interface{}) error {
-	for _, value := range m.ReorderModels(values, false) {
+	for _, value := range m.orderInterleavedModels(m.ReorderModels(values, false), false) {
 		tx := m.DB.Session(&gorm.Session{})
 		if err := m.RunWithValue(value, func(stmt *gorm.Statement) (errr error) {
 			var (
@@ -100,6 +104,10 @@ func (m spannerMigrator) CreateTable(values ...interface{}) error {
 				values                  = []interface{}{m.CurrentTable(stmt)}
 				hasPrimaryKeyInDataType bool
 			)
+			interleaveSQL, interleaveValues, err := m.interleaveClause(stmt.Schema)
+			if err != nil {
+				return err
+			}
 			for _, f := range stmt.Schema.Fields {
 				// Cloud spanner does not support auto incrementing primary keys.
 				if f.AutoIncrement && f.HasDefaultValue && f.DefaultValue == "" && f.DefaultValueInterface == nil {
@@ -118,6 +126,9 @@ func (m spannerMigrator) CreateTable(values ...interface{}) error {
 			for _, dbName := range stmt.Schema.DBNames {
 				field := stmt.Schema.FieldsByDBName[dbName]
 				if !field.IgnoreMigration {
+					if err := m.validateDataType(field); err != nil {
+						return err
+					}
 					createTableSQL += "? ?"
 					hasPrimaryKeyInDataType = hasPrimaryKeyInDataType || strings.Contains(strings.ToUpper(string(field.DataType)), "PRIMARY KEY")
 					values = append(values, clause.Column{Name: dbName}, m.DB.Migrator().FullDataTypeOf(field))
@@ -166,6 +177,9 @@ func (m spannerMigrator) CreateTable(values ...interface{}) error {
 				values = append(values, primaryKeys)
 			}
 
+			createTableSQL += interleaveSQL
+			values = append(values, interleaveValues...)
+
 			if tableOption, ok := m.DB.Get("gorm:table_options"); ok {
 				createTableSQL += fmt.Sprint(tableOption)
 			}
@@ -179,9 +193,48 @@ func (m spannerMigrator) CreateTable(values ...interface{}) error {
 	return nil
 }
 
+// AddColumn adds the column of the field with the given name to the table.
+func (m spannerMigrator) AddColumn(value interface{}, name string) error {
+	if err := m.validateField(value, name); err != nil {
+		return err
+	}
+	return m.Migrator.AddColumn(value, name)
+}
+
+// AlterColumn changes the data type of the column of the field with the given
+// name.
+func (m spannerMigrator) AlterColumn(value interface{}, name string) error {
+	if err := m.validateField(value, name); err != nil {
+		return err
+	}
+	return m.Migrator.AlterColumn(value, name)
+}
+
+func (m spannerMigrator) validateField(value interface{}, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if field := stmt.Schema.LookUpField(name); field != nil {
+			return m.validateDataType(field)
+		}
+		return nil
+	})
+}
+
+// validateDataType returns an error if the field cannot be stored in a column
+// with the data type of the field.
+func (m spannerMigrator) validateDataType(field *schema.Field) error {
+	dataType := m.Migrator.DataTypeOf(field)
+	switch strings.ToUpper(dataType) {
+	case "NUMERIC":
+		return validateNumeric(field)
+	case "ARRAY":
+		return fmt.Errorf("spanner: field %s has type %v, which cannot be stored in an ARRAY column", field.Name, field.FieldType)
+	}
+	return validateSerializer(field, dataType)
+}
+
 // DropTable drop table for values
 func (m spannerMigrator) DropTable(values ...interface{}) error {
-	values = m.ReorderModels(values, false)
+	values = m.orderInterleavedModels(m.ReorderModels(values, false), false)
 	for i := len(values) - 1; i >= 0; i-- {
 		tx := m.DB.Session(&gorm.Session{})
 		if err := m.RunWithValue(values[i], func(stmt *gorm.Statement) error {
@@ -337,7 +390,7 @@ func (c Column) Nullable() (bool, bool) {
 // DecimalSize return precision int64, scale int64, ok bool
 func (c Column) DecimalSize() (int64, int64, bool) {
 	if c.datatype == "NUMERIC" {
-		return 38, 9, true
+		return numericPrecision, numericScale, true
 	}
 	return 0, 0, false
 }
@@ -16,8 +16,12 @@ package gorm
 
 import (
 	"context"
+	"database/sql/driver"
 	"fmt"
+	"math/big"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	emptypb "github.com/golang/protobuf/ptypes/empty"
@@ -126,6 +130,436 @@ func TestMigrate(t *testing.T) {
 	}
 }
 
+func TestMigrateCommitTimestamp(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	err = db.Migrator().AutoMigrate(&event{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := len(request.GetStatements()), 1; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := request.GetStatements()[0],
+		"CREATE TABLE `events` (`id` INT64,`name` STRING(MAX),"+
+			"`created_at` TIMESTAMP OPTIONS (allow_commit_timestamp=true),"+
+			"`updated_at` TIMESTAMP OPTIONS (allow_commit_timestamp=true)) "+
+			"PRIMARY KEY (`id`)"; g != w {
+		t.Fatalf("create events statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+}
+
+type account struct {
+	ID      int64   `gorm:"primarykey;autoIncrement:false"`
+	Balance big.Rat `gorm:"type:numeric"`
+	Limit   spanner.NullNumeric
+	Fee     *spanner.NullNumeric `gorm:"precision:20;scale:9"`
+}
+
+func TestMigrateNumeric(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	err = db.Migrator().AutoMigrate(&account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := len(request.GetStatements()), 1; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := request.GetStatements()[0],
+		"CREATE TABLE `accounts` (`id` INT64,`balance` NUMERIC,`limit` NUMERIC,`fee` NUMERIC) "+
+			"PRIMARY KEY (`id`)"; g != w {
+		t.Fatalf("create accounts statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+}
+
+func TestMigrateJSON(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	err = db.Migrator().AutoMigrate(&venue{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := len(request.GetStatements()), 1; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := request.GetStatements()[0],
+		"CREATE TABLE `venues` (`id` INT64,`description` JSON,`address` JSON) "+
+			"PRIMARY KEY (`id`)"; g != w {
+		t.Fatalf("create venues statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+}
+
+func TestMigrateArray(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	err = db.Migrator().AutoMigrate(&playlist{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := len(request.GetStatements()), 1; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := request.GetStatements()[0],
+		"CREATE TABLE `playlists` (`id` INT64,`genres` ARRAY<STRING(50)>,`track_id` ARRAY<INT64>,"+
+			"`notes` ARRAY<STRING(MAX)>,`ratings` ARRAY<FLOAT64>,`public` ARRAY<BOOL>) PRIMARY KEY (`id`)"; g != w {
+		t.Fatalf("create playlists statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+}
+
+func TestMigrateNumericWithInvalidPrecision(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	type invalidAccount struct {
+		ID      int64               `gorm:"primarykey;autoIncrement:false"`
+		Balance spanner.NullNumeric `gorm:"precision:38;scale:0"`
+	}
+	if err := db.Migrator().CreateTable(&invalidAccount{}); err == nil {
+		t.Fatal("missing expected error for NUMERIC field with precision 38 and scale 0")
+	}
+}
+
+func TestMigrateWithoutSerializer(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	type dateConcert struct {
+		ID        int64     `gorm:"primarykey;autoIncrement:false"`
+		StartDate time.Time `gorm:"type:date"`
+	}
+	type jsonVenue struct {
+		ID      int64   `gorm:"primarykey;autoIncrement:false"`
+		Address address `gorm:"serializer:gob;type:json"`
+	}
+	type arrayPlaylist struct {
+		ID     int64    `gorm:"primarykey;autoIncrement:false"`
+		Genres []string `gorm:"type:array"`
+	}
+	for _, model := range []interface{}{&dateConcert{}, &jsonVenue{}, &arrayPlaylist{}} {
+		if err := db.Migrator().CreateTable(model); err == nil {
+			t.Fatalf("missing expected error for %T", model)
+		}
+	}
+}
+
+func TestMigrateFloat32(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	type discount struct {
+		ID         int64 `gorm:"primarykey;autoIncrement:false"`
+		PercentOff float32
+		Steps      []float32 `gorm:"serializer:spanner_array"`
+	}
+	if err := db.Migrator().CreateTable(&discount{}); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := request.GetStatements()[0],
+		"CREATE TABLE `discounts` (`id` INT64,`percent_off` FLOAT64,`steps` ARRAY<FLOAT64>) PRIMARY KEY (`id`)"; g != w {
+		t.Fatalf("create discounts statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+
+	// float32 values are bound as FLOAT64 values.
+	res := db.Session(&gorm.Session{DryRun: true}).Create(&discount{ID: 1, PercentOff: 0.5, Steps: []float32{0.25}})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if g, w := res.Statement.Vars[1], float64(0.5); g != w {
+		t.Fatalf("percent off mismatch\n Got: %v (%T)\nWant: %v (%T)", g, g, w, w)
+	}
+	steps, err := res.Statement.Vars[2].(driver.Valuer).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := steps, []float64{0.25}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("steps mismatch\n Got: %v (%T)\nWant: %v (%T)", g, g, w, w)
+	}
+}
+
+type invoice struct {
+	ID       int64 `gorm:"primarykey;autoIncrement:false"`
+	Customer string
+}
+
+type invoiceLine struct {
+	ID     int64 `gorm:"primarykey;autoIncrement:false"`
+	LineID int64 `gorm:"primarykey;autoIncrement:false"`
+	Amount float64
+}
+
+func (invoiceLine) InterleaveInParent() (interface{}, string) {
+	return &invoice{}, InterleaveOnDeleteCascade
+}
+
+type invalidInvoiceLine struct {
+	LineID int64 `gorm:"primarykey;autoIncrement:false"`
+	Amount float64
+}
+
+func (invalidInvoiceLine) InterleaveInParent() (interface{}, string) {
+	return &invoice{}, InterleaveOnDeleteNoAction
+}
+
+func TestMigrateInterleavedTable(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	// The parent table is added to the migration and is created first.
+	err = db.Migrator().AutoMigrate(&invoiceLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := len(request.GetStatements()), 2; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := request.GetStatements()[0],
+		"CREATE TABLE `invoices` (`id` INT64,`customer` STRING(MAX)) PRIMARY KEY (`id`)"; g != w {
+		t.Fatalf("create invoices statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+	if g, w := request.GetStatements()[1],
+		"CREATE TABLE `invoice_lines` (`id` INT64,`line_id` INT64,`amount` FLOAT64) "+
+			"PRIMARY KEY (`id`,`line_id`), INTERLEAVE IN PARENT `invoices` ON DELETE CASCADE"; g != w {
+		t.Fatalf("create invoice_lines statement text mismatch\n Got: %s\nWant: %s", g, w)
+	}
+}
+
+func TestMigrateInterleavedTableWithInvalidPrimaryKey(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	if err := db.Migrator().CreateTable(&invalidInvoiceLine{}); err == nil {
+		t.Fatal("missing expected error for interleaved table without the primary key of the parent table")
+	}
+}
+
+type track struct {
+	AlbumID  int64  `gorm:"primarykey;autoIncrement:false;index:idx_tracks_album_title,interleave:albums,storing:duration|Genre"`
+	ID       int64  `gorm:"primarykey;autoIncrement:false"`
+	Title    string `gorm:"index:idx_tracks_album_title,null_filtered,desc"`
+	Duration int64
+	Genre    string `gorm:"uniqueIndex:idx_tracks_genre,sort:desc"`
+}
+
+func TestMigrateIndexOptions(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	anyProto, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TestDatabaseAdmin.SetResps([]proto.Message{
+		&longrunningpb.Operation{
+			Name:   "test-operation",
+			Done:   true,
+			Result: &longrunningpb.Operation_Response{Response: anyProto},
+		},
+	})
+
+	// CreateTable executes each statement separately, unless it is executed in
+	// a DDL batch.
+	m := db.Migrator().(SpannerMigrator)
+	if err := m.StartBatchDDL(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateTable(&track{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RunBatch(); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.TestDatabaseAdmin.Reqs()
+	if g, w := len(requests), 1; g != w {
+		t.Fatalf("request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	request := requests[0].(*databasepb.UpdateDatabaseDdlRequest)
+	if g, w := len(request.GetStatements()), 3; g != w {
+		t.Fatalf("statement count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// gorm creates the indexes of a table in random order.
+	indexes := map[string]bool{}
+	for _, statement := range request.GetStatements()[1:] {
+		indexes[statement] = true
+	}
+	for _, w := range []string{
+		"CREATE NULL_FILTERED INDEX `idx_tracks_album_title` ON `tracks`(`album_id`,`title` DESC) " +
+			"STORING (`duration`,`genre`), INTERLEAVE IN `albums`",
+		"CREATE UNIQUE INDEX `idx_tracks_genre` ON `tracks`(`genre` DESC)",
+	} {
+		if !indexes[w] {
+			t.Fatalf("missing create index statement\n Got: %v\nWant: %s", request.GetStatements()[1:], w)
+		}
+	}
+}
+
+func TestHasIndex(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+	_ = server.TestSpanner.PutStatementResult(
+		"SELECT count(*) FROM information_schema.indexes WHERE table_schema = '' AND table_name = @p1 AND index_name = @p2",
+		&testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: testutil.CreateSingleColumnResultSet([]int64{1}, "")},
+	)
+
+	if !db.Migrator().HasIndex(&track{}, "idx_tracks_genre") {
+		t.Fatal("missing expected index idx_tracks_genre")
+	}
+	// The index of a field is found by the name of the field.
+	if !db.Migrator().HasIndex(&track{}, "Genre") {
+		t.Fatal("missing expected index for field Genre")
+	}
+}
+
+func TestMigratorInTransaction(t *testing.T) {
+	t.Parallel()
+
+	server, _, serverTeardown := setupMockedTestServer(t)
+	defer serverTeardown()
+	// Prepared statements are disabled, so the transaction uses the connection
+	// pool of the dialect directly.
+	db, err := gorm.Open(New(Config{
+		DriverName: "spanner",
+		DSN:        fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := tx.DB(); err != nil {
+			return err
+		}
+		// The table does not exist on the mock server.
+		if tx.Migrator().HasTable(&singer{}) {
+			t.Fatal("unexpected table singers")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func setupTestGormConnection(t *testing.T) (db *gorm.DB, server *testutil.MockedSpannerInMemTestServer, teardown func()) {
 	return setupTestGormConnectionWithParams(t, "")
 }
@@ -0,0 +1,332 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// UseMutationsKey is the gorm setting that instructs the Cloud Spanner dialect
+// to use mutations instead of DML for Create, Save, Update and Delete.
+const UseMutationsKey = "spanner:use_mutations"
+
+// WithMutations is a scope that instructs gorm to use mutations instead of DML
+// for Create, Save, Update and Delete operations. Mutations are buffered in the
+// current transaction, or applied directly if there is no transaction.
+//
+// Mutations can only be used for records that have a primary key value. Update
+// and Delete operations may therefore not contain any additional WHERE
+// conditions. Save uses an InsertOrUpdate mutation, which also inserts the
+// record if it does not exist. Mutations that are buffered in a transaction are only sent to
+// Cloud Spanner when the transaction commits, which means that errors, such as
+// a duplicate key, are returned by the commit, and that the changes are not
+// visible to queries in the same transaction.
+//
+// Example:
+//
+//	db.Scopes(spannergorm.WithMutations).Create(&singer)
+func WithMutations(db *gorm.DB) *gorm.DB {
+	return db.Set(UseMutationsKey, true)
+}
+
+func useMutations(db *gorm.DB) bool {
+	if v, ok := db.Get(UseMutationsKey); ok {
+		useMutations, _ := v.(bool)
+		return useMutations
+	}
+	return false
+}
+
+func createMutations(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if db.Statement.Schema == nil {
+		_ = db.AddError(fmt.Errorf("spanner: mutations can only be used with a model"))
+		return
+	}
+	values := callbacks.ConvertToCreateValues(db.Statement)
+	if db.Error != nil {
+		return
+	}
+	op := spanner.Insert
+	if c, ok := db.Statement.Clauses[clause.OnConflict{}.Name()]; ok {
+		if onConflict, ok := c.Expression.(clause.OnConflict); ok {
+			modifier, err := insertModifier(db.Statement, onConflict, values.Columns)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			if modifier != "OR UPDATE" {
+				_ = db.AddError(fmt.Errorf("spanner: OnConflict without updates cannot be used with mutations"))
+				return
+			}
+			op = spanner.InsertOrUpdate
+		}
+	}
+	columns := make([]string, len(values.Columns))
+	for i, column := range values.Columns {
+		columns[i] = column.Name
+	}
+	mutations := make([]*spanner.Mutation, 0, len(values.Values))
+	for _, row := range values.Values {
+		row = append([]interface{}{}, row...)
+		for i, column := range columns {
+			row[i] = jsonFieldValue(db.Statement.Schema, column, row[i])
+		}
+		vals, err := mutationValues(row)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		for i, column := range columns {
+			if commitTimestampField(db.Statement.Schema, column) != nil {
+				vals[i] = spanner.CommitTimestamp
+			}
+		}
+		mutations = append(mutations, op(db.Statement.Table, columns, vals))
+	}
+	applyMutations(db, mutations)
+}
+
+func updateMutations(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if db.Statement.Schema == nil {
+		_ = db.AddError(fmt.Errorf("spanner: mutations can only be used with a model"))
+		return
+	}
+	if _, ok := db.Statement.Clauses["WHERE"]; ok {
+		_ = db.AddError(fmt.Errorf("spanner: mutations can only update records by primary key and cannot be used with WHERE conditions"))
+		return
+	}
+	primaryKeys, err := primaryKeyValues(db.Statement)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	set := callbacks.ConvertToAssignments(db.Statement)
+	if db.Error != nil || len(set) == 0 {
+		return
+	}
+	columns := append([]string{}, db.Statement.Schema.PrimaryFieldDBNames...)
+	setValues := make([]interface{}, 0, len(set))
+	for _, assignment := range set {
+		if _, ok := assignment.Value.(clause.Expression); ok {
+			_ = db.AddError(fmt.Errorf("spanner: mutations cannot assign an expression to column %s", assignment.Column.Name))
+			return
+		}
+		value := assignment.Value
+		if field := commitTimestampField(db.Statement.Schema, assignment.Column.Name); field != nil {
+			if field.AutoCreateTime > 0 {
+				continue
+			}
+			value = spanner.CommitTimestamp
+		}
+		columns = append(columns, assignment.Column.Name)
+		setValues = append(setValues, jsonFieldValue(db.Statement.Schema, assignment.Column.Name, value))
+	}
+	// Save inserts the record if it does not exist. gorm does this by
+	// creating the record if the update did not affect any rows, but a buffered
+	// mutation does not return the number of affected rows. Save therefore
+	// uses an InsertOrUpdate mutation.
+	op := spanner.Update
+	if isSave(db.Statement) {
+		op = spanner.InsertOrUpdate
+	}
+	mutations := make([]*spanner.Mutation, 0, len(primaryKeys))
+	for _, key := range primaryKeys {
+		vals, err := mutationValues(append(append([]interface{}{}, key...), setValues...))
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		mutations = append(mutations, op(db.Statement.Table, columns, vals))
+	}
+	applyMutations(db, mutations)
+}
+
+// isSave returns true if the given update statement was started by Save. Save
+// selects all columns of a struct with a primary key value.
+func isSave(stmt *gorm.Statement) bool {
+	if len(stmt.Selects) != 1 || stmt.Selects[0] != "*" {
+		return false
+	}
+	return stmt.ReflectValue.Kind() == reflect.Struct
+}
+
+func deleteMutations(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if db.Statement.Schema == nil {
+		_ = db.AddError(fmt.Errorf("spanner: mutations can only be used with a model"))
+		return
+	}
+	if _, ok := db.Statement.Clauses["WHERE"]; ok {
+		_ = db.AddError(fmt.Errorf("spanner: mutations can only delete records by primary key and cannot be used with WHERE conditions"))
+		return
+	}
+	primaryKeys, err := primaryKeyValues(db.Statement)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+
+	// Soft deletes are translated to an update of the DeletedAt column.
+	if !db.Statement.Unscoped {
+		for _, c := range db.Statement.Schema.DeleteClauses {
+			if softDelete, ok := c.(gorm.SoftDeleteDeleteClause); ok {
+				curTime := db.Statement.DB.NowFunc()
+				db.Statement.SetColumn(softDelete.Field.DBName, curTime, true)
+				columns := append(append([]string{}, db.Statement.Schema.PrimaryFieldDBNames...), softDelete.Field.DBName)
+				mutations := make([]*spanner.Mutation, 0, len(primaryKeys))
+				for _, key := range primaryKeys {
+					vals, err := mutationValues(append(append([]interface{}{}, key...), curTime))
+					if err != nil {
+						_ = db.AddError(err)
+						return
+					}
+					mutations = append(mutations, spanner.Update(db.Statement.Table, columns, vals))
+				}
+				applyMutations(db, mutations)
+				return
+			}
+		}
+	}
+
+	keys := make([]spanner.Key, 0, len(primaryKeys))
+	for _, key := range primaryKeys {
+		vals, err := mutationValues(key)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		keys = append(keys, vals)
+	}
+	applyMutations(db, []*spanner.Mutation{spanner.Delete(db.Statement.Table, spanner.KeySetFromKeys(keys...))})
+	if db.Error == nil {
+		db.RowsAffected = int64(len(keys))
+	}
+}
+
+// primaryKeyValues returns the primary key values of the records in the
+// statement. An error is returned if the statement does not contain any
+// records with a primary key value.
+func primaryKeyValues(stmt *gorm.Statement) ([][]interface{}, error) {
+	if len(stmt.Schema.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("spanner: mutations can only be used for models with a primary key")
+	}
+	_, primaryKeys := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+	if len(primaryKeys) == 0 {
+		return nil, gorm.ErrPrimaryKeyRequired
+	}
+	return primaryKeys, nil
+}
+
+// mutationValues converts the values that gorm generated for a statement to
+// values that can be used in a mutation.
+func mutationValues(values []interface{}) ([]interface{}, error) {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		if valuer, ok := value.(driver.Valuer); ok {
+			if rv := reflect.ValueOf(valuer); rv.Kind() == reflect.Ptr && rv.IsNil() {
+				continue
+			}
+			v, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+		v, err := integerValue(value)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = float64Value(v)
+	}
+	return result, nil
+}
+
+// integerValue converts unsigned integers and integers with less than 64 bits
+// to int64, as the Cloud Spanner client only supports int and int64 values in
+// mutations. gorm.Model for example uses an uint primary key. Pointers to
+// integers are converted to an int64 or nil.
+func integerValue(value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		switch rv.Type().Elem().Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.IsNil() {
+				return nil, nil
+			}
+			rv = rv.Elem()
+		default:
+			return value, nil
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("spanner: value %d is out of range for an INT64 column", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	}
+	return value, nil
+}
+
+// applyMutations buffers the given mutations in the current transaction, or
+// applies them directly if the statement is not executed in a transaction.
+func applyMutations(db *gorm.DB, mutations []*spanner.Mutation) {
+	if db.DryRun || db.Error != nil || len(mutations) == 0 {
+		return
+	}
+	var err error
+	if tx := transactionOf(db); tx != nil {
+		// Record the mutations in the transaction, so they can be replayed
+		// when the transaction is rolled back to a savepoint.
+		err = tx.bufferWrite(db, mutations)
+	} else {
+		err = withSpannerConn(db, func(conn spannerdriver.SpannerConn) error {
+			if inTransaction(db) {
+				return conn.BufferWrite(mutations)
+			}
+			commitTimestamp, err := conn.Apply(db.Statement.Context, mutations)
+			if err == nil {
+				db.InstanceSet(commitTimestampKey, commitTimestamp)
+			}
+			return err
+		})
+	}
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	db.RowsAffected = int64(len(mutations))
+}
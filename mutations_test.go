@@ -0,0 +1,195 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestCreateWithMutations(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	s := singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}
+	if err := db.Scopes(WithMutations).Create(&s).Error; err != nil {
+		t.Fatal(err)
+	}
+	if s.CreatedAt.IsZero() {
+		t.Fatal("CreatedAt has not been set")
+	}
+	mutations := committedMutations(t, server.TestSpanner)
+	if g, w := len(mutations), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	insert := mutations[0].GetInsert()
+	if insert == nil {
+		t.Fatalf("mutation type mismatch\n Got: %v\nWant: Insert", mutations[0])
+	}
+	if g, w := insert.Table, "singers"; g != w {
+		t.Fatalf("table mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := insert.Columns, []string{"created_at", "updated_at", "deleted_at", "first_name", "last_name", "full_name", "active", "id"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("columns mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestUpdateWithMutations(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	s := singer{Model: gorm.Model{ID: 1}}
+	if err := db.Scopes(WithMutations).Model(&s).Update("first_name", "First").Error; err != nil {
+		t.Fatal(err)
+	}
+	mutations := committedMutations(t, server.TestSpanner)
+	if g, w := len(mutations), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	update := mutations[0].GetUpdate()
+	if update == nil {
+		t.Fatalf("mutation type mismatch\n Got: %v\nWant: Update", mutations[0])
+	}
+	if g, w := update.Columns, []string{"id", "first_name", "updated_at"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("columns mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if err := db.Scopes(WithMutations).Model(&singer{}).Where("active = ?", true).Update("first_name", "First").Error; err == nil {
+		t.Fatal("missing expected error for update with WHERE condition")
+	}
+}
+
+func TestSaveWithMutations(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	// Save of a new record with a primary key value must insert the record.
+	s := singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}
+	if err := db.Scopes(WithMutations).Save(&s).Error; err != nil {
+		t.Fatal(err)
+	}
+	mutations := committedMutations(t, server.TestSpanner)
+	if g, w := len(mutations), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	insertOrUpdate := mutations[0].GetInsertOrUpdate()
+	if insertOrUpdate == nil {
+		t.Fatalf("mutation type mismatch\n Got: %v\nWant: InsertOrUpdate", mutations[0])
+	}
+	if g, w := insertOrUpdate.Columns, []string{"id", "created_at", "updated_at", "deleted_at", "first_name", "last_name", "full_name", "active"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("columns mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestDeleteWithMutations(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	s := singer{Model: gorm.Model{ID: 1}}
+	if err := db.Scopes(WithMutations).Delete(&s).Error; err != nil {
+		t.Fatal(err)
+	}
+	mutations := committedMutations(t, server.TestSpanner)
+	if g, w := len(mutations), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	// singer uses soft deletes.
+	update := mutations[0].GetUpdate()
+	if update == nil {
+		t.Fatalf("mutation type mismatch\n Got: %v\nWant: Update", mutations[0])
+	}
+	if g, w := update.Columns, []string{"id", "deleted_at"}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("columns mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	if err := db.Scopes(WithMutations).Unscoped().Delete(&s).Error; err != nil {
+		t.Fatal(err)
+	}
+	mutations = committedMutations(t, server.TestSpanner)
+	if g, w := len(mutations), 1; g != w {
+		t.Fatalf("mutation count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	del := mutations[0].GetDelete()
+	if del == nil {
+		t.Fatalf("mutation type mismatch\n Got: %v\nWant: Delete", mutations[0])
+	}
+	if g, w := len(del.KeySet.Keys), 1; g != w {
+		t.Fatalf("key count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestMutationValues(t *testing.T) {
+	t.Parallel()
+
+	id := uint(2)
+	var nilID *uint32
+	values, err := mutationValues([]interface{}{uint(1), &id, nilID, int32(3), uint8(4), float32(0.5), "test", []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := values, []interface{}{int64(1), int64(2), nil, int64(3), int64(4), float64(0.5), "test", []byte("test")}; !reflect.DeepEqual(g, w) {
+		t.Fatalf("values mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if _, err := mutationValues([]interface{}{uint64(math.MaxUint64)}); err == nil {
+		t.Fatal("missing expected error for out of range value")
+	}
+}
+
+func committedMutations(t *testing.T, server testutil.InMemSpannerServer) []*spannerpb.Mutation {
+	requests := drainRequestsFromServer(server)
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	return commitRequests[0].(*spannerpb.CommitRequest).Mutations
+}
+
+func requestsOfType(requests []interface{}, t reflect.Type) []interface{} {
+	res := make([]interface{}, 0)
+	for _, req := range requests {
+		if reflect.TypeOf(req) == t {
+			res = append(res, req)
+		}
+	}
+	return res
+}
+
+func drainRequestsFromServer(server testutil.InMemSpannerServer) []interface{} {
+	var reqs []interface{}
+loop:
+	for {
+		select {
+		case req := <-server.ReceivedRequests():
+			reqs = append(reqs, req)
+		default:
+			break loop
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+)
+
+const partitionedDMLKey = "spanner:partitioned_dml"
+
+// WithPartitionedDML is a scope that instructs gorm to execute Update and
+// Delete operations as Partitioned DML. Partitioned DML is not executed
+// atomically, and is intended for bulk updates and deletes that would
+// otherwise exceed the mutation limit of a transaction. The number of affected
+// rows that is returned for a Partitioned DML statement is a lower bound.
+//
+// Partitioned DML cannot be executed in a transaction. The scope therefore
+// also disables the default transaction that gorm uses for write operations.
+// See https://cloud.google.com/spanner/docs/dml-partitioned for more
+// information on Partitioned DML.
+//
+// Example:
+//
+//	db.Scopes(spannergorm.WithPartitionedDML).Where("active = ?", false).Delete(&Singer{})
+func WithPartitionedDML(db *gorm.DB) *gorm.DB {
+	return db.Set(partitionedDMLKey, true).Session(&gorm.Session{SkipDefaultTransaction: true})
+}
+
+func usePartitionedDML(db *gorm.DB) bool {
+	if v, ok := db.Get(partitionedDMLKey); ok {
+		usePartitionedDML, _ := v.(bool)
+		return usePartitionedDML
+	}
+	return false
+}
+
+// executePartitionedDML executes the statement on a single connection that
+// uses Partitioned DML for statements that are executed in autocommit mode.
+func executePartitionedDML(db *gorm.DB, exec func(db *gorm.DB)) {
+	if db.Error != nil {
+		return
+	}
+	if inTransaction(db) {
+		_ = db.AddError(fmt.Errorf("spanner: Partitioned DML cannot be executed in a transaction"))
+		return
+	}
+	conn, release, err := sqlConn(db.Statement.Context, db.Statement.ConnPool)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	defer release()
+	if err := rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+		return conn.SetAutocommitDMLMode(spannerdriver.PartitionedNonAtomic)
+	}); err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	defer func() {
+		_ = db.AddError(rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+			return conn.SetAutocommitDMLMode(spannerdriver.Transactional)
+		}))
+	}()
+
+	pool := db.Statement.ConnPool
+	db.Statement.ConnPool = conn
+	defer func() {
+		db.Statement.ConnPool = pool
+	}()
+	exec(db)
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestDeleteWithPartitionedDML(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(
+		"UPDATE `singers` SET `deleted_at`=@p1 WHERE active = @p2 AND `singers`.`deleted_at` IS NULL",
+		&testutil.StatementResult{Type: testutil.StatementResultUpdateCount, UpdateCount: 100},
+	)
+	res := db.Scopes(WithPartitionedDML).Where("active = ?", false).Delete(&singer{})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if g, w := res.RowsAffected, int64(100); g != w {
+		t.Fatalf("rows affected mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("begin request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if beginRequests[0].(*spannerpb.BeginTransactionRequest).GetOptions().GetPartitionedDml() == nil {
+		t.Fatal("statement was not executed as Partitioned DML")
+	}
+}
+
+func TestPartitionedDMLInTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Scopes(WithPartitionedDML).Where("active = ?", false).Delete(&singer{}).Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for Partitioned DML in a transaction")
+	}
+}
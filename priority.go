@@ -0,0 +1,230 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+)
+
+// rpcPriorityParam is the connection property that the Cloud Spanner
+// database/sql driver uses for the priority of all requests on a connection.
+const rpcPriorityParam = "rpcPriority"
+
+// Priority returns a scope that executes a statement with the given priority.
+// The Cloud Spanner database/sql driver only supports setting a priority for
+// all requests of a connection. The statement is therefore executed on a
+// separate connection pool that is opened for the priority the first time it
+// is used. These connection pools are closed when the *sql.DB of db is
+// closed. Priority cannot be used for statements in a transaction. Use
+// WithPriority to start a transaction with a priority instead.
+//
+// Priority cannot be used if the Dialector is configured with Conn.
+//
+// Example:
+//
+//	db.Scopes(spannergorm.Priority(spannerpb.RequestOptions_PRIORITY_LOW)).Find(&singers)
+func Priority(priority spannerpb.RequestOptions_Priority) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// Clone the statement, so the connection pool of the original
+		// statement is not changed.
+		tx := db.WithContext(db.Statement.Context)
+		if inTransaction(tx) {
+			_ = tx.AddError(fmt.Errorf("spanner: Priority cannot be used for statements in a transaction, use WithPriority to start the transaction with a priority"))
+			return tx
+		}
+		pool, err := connPoolWithPriority(tx.Statement.ConnPool, priority)
+		if err != nil {
+			_ = tx.AddError(err)
+			return tx
+		}
+		tx.Statement.ConnPool = pool
+		return tx
+	}
+}
+
+// WithPriority returns a session that executes all statements and
+// transactions with the given priority. This includes transactions that are
+// started with Begin, Transaction, RunTransaction and ReadOnlyTransaction.
+//
+// Example:
+//
+//	low := spannergorm.WithPriority(db, spannerpb.RequestOptions_PRIORITY_LOW)
+//	err := low.Transaction(func(tx *gorm.DB) error {
+//		return tx.Create(&singer).Error
+//	})
+func WithPriority(db *gorm.DB, priority spannerpb.RequestOptions_Priority) *gorm.DB {
+	return Priority(priority)(db)
+}
+
+// priorityPools contains the connection pools of a Dialector for each
+// priority that has been used. The pools are closed when the connection pool
+// of the Dialector is closed.
+type priorityPools struct {
+	driverName string
+	dsn        string
+	// defaultPriority is the priority of the connection pool of the Dialector.
+	defaultPriority spannerpb.RequestOptions_Priority
+
+	mu     sync.Mutex
+	pools  map[spannerpb.RequestOptions_Priority]*connPool
+	closed bool
+}
+
+// pool returns the connection pool for the given priority, and opens the pool
+// if it has not yet been opened.
+func (p *priorityPools) pool(priority spannerpb.RequestOptions_Priority) (*connPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pool, ok := p.pools[priority]; ok {
+		return pool, nil
+	}
+	if p.closed {
+		return nil, fmt.Errorf("spanner: cannot open a connection pool with priority %v, as the database is closed", priority)
+	}
+	db, err := sql.Open(p.driverName, dsnWithPriority(p.dsn, priority))
+	if err != nil {
+		return nil, err
+	}
+	pool := &connPool{DB: db, priorities: p}
+	p.pools[priority] = pool
+	return pool, nil
+}
+
+// Close closes the connection pools that have been opened for other
+// priorities than the default priority.
+func (p *priorityPools) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var err error
+	for priority, pool := range p.pools {
+		if priority == p.defaultPriority {
+			continue
+		}
+		if closeErr := pool.DB.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(p.pools, priority)
+	}
+	return err
+}
+
+// openDB opens the connection pool of a Dialector. The connection pools of
+// the given priorityPools are closed when the returned *sql.DB is closed.
+func openDB(driverName, dsn string, priorities *priorityPools) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// sql.Open does not open any connections, and the *sql.DB is only used to
+	// get the driver.
+	d := db.Driver()
+	_ = db.Close()
+	var connector driver.Connector = dsnConnector{dsn: dsn, driver: d}
+	if dc, ok := d.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(closingConnector{Connector: connector, closer: priorities}), nil
+}
+
+// dsnConnector is a driver.Connector for drivers that do not implement
+// driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// closingConnector is a driver.Connector that closes closer when the *sql.DB
+// of the connector is closed.
+type closingConnector struct {
+	driver.Connector
+	closer io.Closer
+}
+
+// Close implements io.Closer. database/sql calls Close when the *sql.DB is
+// closed.
+func (c closingConnector) Close() error {
+	err := c.closer.Close()
+	if closer, ok := c.Connector.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// connPoolWithPriority returns the connection pool for the given priority
+// that belongs to the given connection pool.
+func connPoolWithPriority(pool gorm.ConnPool, priority spannerpb.RequestOptions_Priority) (*connPool, error) {
+	var p *connPool
+	switch c := pool.(type) {
+	case *connPool:
+		p = c
+	case *gorm.PreparedStmtDB:
+		p, _ = c.ConnPool.(*connPool)
+	}
+	if p == nil || p.priorities == nil {
+		return nil, fmt.Errorf("spanner: Priority can only be used with connections that are opened with a DSN, and not with Conn")
+	}
+	return p.priorities.pool(priority)
+}
+
+// dsnWithPriority returns the given DSN with the rpcPriority connection
+// property set to the given priority. The Cloud Spanner database/sql driver
+// uses this priority for all queries, DML statements and commits on the
+// connection. An rpcPriority property in the DSN is replaced. The DSN is
+// returned unmodified if the priority is unspecified.
+func dsnWithPriority(dsn string, priority spannerpb.RequestOptions_Priority) string {
+	if priority == spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+		return dsn
+	}
+	// The connection properties follow the database name, and start with
+	// either a '?' or a ';'.
+	database, params, separator := dsn, "", "?"
+	if start := strings.Index(dsn, "projects/"); start >= 0 {
+		if i := strings.IndexAny(dsn[start:], "?;"); i >= 0 {
+			database, params, separator = dsn[:start+i], dsn[start+i+1:], dsn[start+i:start+i+1]
+		}
+	}
+	var properties []string
+	for _, property := range strings.Split(params, ";") {
+		name, _, _ := strings.Cut(property, "=")
+		if property == "" || strings.EqualFold(strings.TrimSpace(name), rpcPriorityParam) {
+			continue
+		}
+		properties = append(properties, property)
+	}
+	properties = append(properties, rpcPriorityParam+"="+strings.TrimPrefix(priority.String(), "PRIORITY_"))
+	return database + separator + strings.Join(properties, ";")
+}
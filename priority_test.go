@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestDSNWithPriority(t *testing.T) {
+	t.Parallel()
+
+	const dsn = "projects/p/instances/i/databases/d"
+	for _, test := range []struct {
+		priority spannerpb.RequestOptions_Priority
+		want     string
+	}{
+		{spannerpb.RequestOptions_PRIORITY_UNSPECIFIED, dsn},
+		{spannerpb.RequestOptions_PRIORITY_LOW, dsn + "?rpcPriority=LOW"},
+		{spannerpb.RequestOptions_PRIORITY_MEDIUM, dsn + "?rpcPriority=MEDIUM"},
+		{spannerpb.RequestOptions_PRIORITY_HIGH, dsn + "?rpcPriority=HIGH"},
+	} {
+		if g, w := dsnWithPriority(dsn, test.priority), test.want; g != w {
+			t.Errorf("%v: dsn mismatch\n Got: %v\nWant: %v", test.priority, g, w)
+		}
+	}
+}
+
+func TestDSNWithPriorityReplacesProperty(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		dsn  string
+		want string
+	}{
+		{"projects/p/instances/i/databases/d?usePlainText=true", "projects/p/instances/i/databases/d?usePlainText=true;rpcPriority=LOW"},
+		{"projects/p/instances/i/databases/d?usePlainText=true;", "projects/p/instances/i/databases/d?usePlainText=true;rpcPriority=LOW"},
+		{"projects/p/instances/i/databases/d;rpcPriority=HIGH", "projects/p/instances/i/databases/d;rpcPriority=LOW"},
+		{"localhost:9010/projects/p/instances/i/databases/d?RPCPRIORITY=HIGH;minSessions=1", "localhost:9010/projects/p/instances/i/databases/d?minSessions=1;rpcPriority=LOW"},
+	} {
+		if g, w := dsnWithPriority(test.dsn, spannerpb.RequestOptions_PRIORITY_LOW), test.want; g != w {
+			t.Errorf("%v: dsn mismatch\n Got: %v\nWant: %v", test.dsn, g, w)
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(
+		"SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL",
+		&testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: testutil.CreateSingleColumnResultSet([]int64{1}, "id")},
+	)
+	var singers []singer
+	if err := db.Scopes(Priority(spannerpb.RequestOptions_PRIORITY_LOW)).Find(&singers).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Find(&singers).Error; err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("sql request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for i, want := range []spannerpb.RequestOptions_Priority{spannerpb.RequestOptions_PRIORITY_LOW, spannerpb.RequestOptions_PRIORITY_UNSPECIFIED} {
+		if g, w := sqlRequests[i].(*spannerpb.ExecuteSqlRequest).GetRequestOptions().GetPriority(), want; g != w {
+			t.Fatalf("%d: priority mismatch\n Got: %v\nWant: %v", i, g, w)
+		}
+	}
+}
+
+func TestPriorityInTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var singers []singer
+		return tx.Scopes(Priority(spannerpb.RequestOptions_PRIORITY_LOW)).Find(&singers).Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for Priority in a transaction")
+	}
+}
+
+func TestDefaultPriorityWithConn(t *testing.T) {
+	t.Parallel()
+
+	sqlDB, err := sql.Open("spanner", "projects/p/instances/i/databases/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	if _, err := gorm.Open(New(Config{Conn: sqlDB, DefaultPriority: spannerpb.RequestOptions_PRIORITY_LOW}), &gorm.Config{DisableAutomaticPing: true}); err == nil {
+		t.Fatal("missing expected error for DefaultPriority with Conn")
+	}
+	db, err := gorm.Open(New(Config{Conn: sqlDB}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var singers []singer
+	if err := db.Scopes(Priority(spannerpb.RequestOptions_PRIORITY_LOW)).Find(&singers).Error; err == nil {
+		t.Fatal("missing expected error for Priority with Conn")
+	}
+}
+
+func TestClosePriorityPools(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	pool, err := connPoolWithPriority(db.Statement.ConnPool, spannerpb.RequestOptions_PRIORITY_LOW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing the database also closes the connection pools for other
+	// priorities.
+	if err := pool.PingContext(context.Background()); err == nil {
+		t.Fatal("missing expected error for closed priority pool")
+	}
+	var singers []singer
+	if err := db.Scopes(Priority(spannerpb.RequestOptions_PRIORITY_MEDIUM)).Find(&singers).Error; err == nil {
+		t.Fatal("missing expected error for Priority on a closed database")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gorm.io/gorm/schema"
+)
+
+// The versions of the Cloud Spanner client library and database/sql driver
+// that this dialect uses do not support PROTO and ENUM columns. Fields with
+// the tag `gorm:"serializer:spanner_proto"` are instead stored in BYTES
+// columns if they are a pointer to a proto message, and in INT64 columns if
+// they are a proto enum. Messages are marshaled with proto.Marshal when they
+// are written, and are unmarshaled with proto.Unmarshal when they are read.
+//
+// Example:
+//
+//	type Singer struct {
+//		ID    int64
+//		Info  *pb.SingerInfo `gorm:"serializer:spanner_proto"`
+//		Genre pb.Genre       `gorm:"serializer:spanner_proto"`
+//	}
+
+// protoSerializerName is the name of the serializer for proto message and
+// enum fields.
+const protoSerializerName = "spanner_proto"
+
+var protoEnumType = reflect.TypeOf((*protoreflect.Enum)(nil)).Elem()
+
+// isProtoEnumField returns whether the field is a proto enum or a pointer to
+// a proto enum.
+func isProtoEnumField(field *schema.Field) bool {
+	return field.IndirectFieldType != nil && field.IndirectFieldType.Implements(protoEnumType)
+}
+
+// protoSerializer binds a proto message as the BYTES value that is returned by
+// proto.Marshal, and a proto enum as the INT64 number of the enum value.
+type protoSerializer struct{}
+
+// Scan implements schema.SerializerInterface.
+func (protoSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	if dbValue == nil {
+		field.ReflectValueOf(ctx, dst).Set(reflect.Zero(field.FieldType))
+		return nil
+	}
+	if isProtoEnumField(field) {
+		var number sql.NullInt64
+		if err := number.Scan(dbValue); err != nil {
+			return err
+		}
+		return field.Set(ctx, dst, reflect.ValueOf(number.Int64).Convert(field.IndirectFieldType).Interface())
+	}
+	b, ok := dbValue.([]byte)
+	if !ok {
+		return fmt.Errorf("spanner: cannot scan a value of type %T into proto field %s", dbValue, field.Name)
+	}
+	msg, ok := reflect.New(field.IndirectFieldType).Interface().(proto.Message)
+	if !ok || field.FieldType.Kind() != reflect.Ptr {
+		return fmt.Errorf("spanner: field %s has type %v, which is not a pointer to a proto message or a proto enum", field.Name, field.FieldType)
+	}
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return err
+	}
+	field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(msg))
+	return nil
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (protoSerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if rv := reflect.ValueOf(fieldValue); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		if isProtoEnumField(field) {
+			return sql.NullInt64{}, nil
+		}
+		return []byte(nil), nil
+	}
+	switch v := fieldValue.(type) {
+	case protoreflect.Enum:
+		return int64(v.Number()), nil
+	case proto.Message:
+		return proto.Marshal(v)
+	}
+	return nil, fmt.Errorf("spanner: field %s has type %v, which is not a pointer to a proto message or a proto enum", field.Name, field.FieldType)
+}
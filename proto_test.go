@@ -0,0 +1,85 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gorm.io/gorm"
+)
+
+type protoRecord struct {
+	ID           int64                   `gorm:"primarykey;autoIncrement:false"`
+	Description  *wrapperspb.StringValue `gorm:"serializer:spanner_proto"`
+	Kind         structpb.NullValue      `gorm:"serializer:spanner_proto"`
+	PreviousKind *structpb.NullValue     `gorm:"serializer:spanner_proto"`
+}
+
+func TestProtoFields(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	r := protoRecord{ID: 1, Description: wrapperspb.String("test")}
+	res := db.Session(&gorm.Session{DryRun: true}).Create(&r)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	description, err := proto.Marshal(r.Description)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{description, int64(0), sql.NullInt64{}}
+	for i, w := range want {
+		g, err := res.Statement.Vars[i+1].(driver.Valuer).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Fatalf("value mismatch for var %d\n Got: %v\nWant: %v", i+1, g, w)
+		}
+	}
+	for name, want := range map[string]string{"Description": "BYTES(MAX)", "Kind": "INT64", "PreviousKind": "INT64"} {
+		if g, w := db.Dialector.DataTypeOf(res.Statement.Schema.LookUpField(name)), want; g != w {
+			t.Fatalf("data type mismatch for %s\n Got: %v\nWant: %v", name, g, w)
+		}
+	}
+
+	var scanned protoRecord
+	for name, dbValue := range map[string]interface{}{"Description": description, "PreviousKind": int64(0)} {
+		field := res.Statement.Schema.LookUpField(name)
+		value := field.NewValuePool.Get()
+		if err := value.(sql.Scanner).Scan(dbValue); err != nil {
+			t.Fatal(err)
+		}
+		if err := field.Set(context.Background(), reflect.ValueOf(&scanned).Elem(), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g, w := scanned.Description.GetValue(), "test"; g != w {
+		t.Fatalf("description mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := scanned.PreviousKind, structpb.NullValue_NULL_VALUE; g == nil || *g != w {
+		t.Fatalf("previous kind mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
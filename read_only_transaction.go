@@ -0,0 +1,134 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"database/sql"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+)
+
+const readOnlyTransactionKey = "spanner:read_only_transaction"
+
+var readOnlyTxOptions = &sql.TxOptions{ReadOnly: true}
+
+// ReadOnlyTransactionOptions contains the options for a read-only transaction.
+type ReadOnlyTransactionOptions struct {
+	// TimestampBound determines the read timestamp of the transaction. The
+	// default is a strong read. A read-only transaction can only use a
+	// strong read, an exact staleness or a read timestamp.
+	TimestampBound spanner.TimestampBound
+}
+
+// ReadOnlyTransaction executes fc in a Cloud Spanner read-only transaction.
+// All queries in the transaction read data from the same snapshot and do not
+// take any locks. Create, Update, Delete and Exec operations in the
+// transaction return an error.
+//
+// The read timestamp of the transaction is chosen by Cloud Spanner and is not
+// exposed by the Cloud Spanner database/sql driver. Use the ReadTimestamp
+// option to read multiple transactions at the same known timestamp.
+//
+// Example:
+//
+//	err := spannergorm.ReadOnlyTransaction(db, &spannergorm.ReadOnlyTransactionOptions{
+//		TimestampBound: spanner.ExactStaleness(15 * time.Second),
+//	}, func(tx *gorm.DB) error {
+//		if err := tx.Find(&singers).Error; err != nil {
+//			return err
+//		}
+//		return tx.Model(&Album{}).Count(&albumCount).Error
+//	})
+func ReadOnlyTransaction(db *gorm.DB, opts *ReadOnlyTransactionOptions, fc func(tx *gorm.DB) error) error {
+	staleness := spanner.StrongRead()
+	if opts != nil {
+		staleness = opts.TimestampBound
+	}
+	return runReadOnlyTransaction(db, staleness, func(ct *connTx) error {
+		tx := db.Session(&gorm.Session{Context: db.Statement.Context})
+		tx.Statement.Settings.Store(readOnlyTransactionKey, true)
+		tx.Statement.ConnPool = ct
+		return fc(tx)
+	})
+}
+
+// runReadOnlyTransaction executes f in a read-only transaction with the given
+// timestamp bound on a dedicated connection.
+func runReadOnlyTransaction(db *gorm.DB, staleness spanner.TimestampBound, f func(ct *connTx) error) error {
+	if inTransaction(db) {
+		return fmt.Errorf("spanner: a read-only transaction cannot be started in a transaction")
+	}
+	ctx := db.Statement.Context
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, release, err := sqlConn(ctx, db.Statement.ConnPool)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+		return conn.SetReadOnlyStaleness(staleness)
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		_ = rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+			return conn.SetReadOnlyStaleness(spanner.StrongRead())
+		})
+	}()
+
+	sqlTx, err := conn.BeginTx(ctx, readOnlyTxOptions)
+	if err != nil {
+		return err
+	}
+	ct := &connTx{Tx: sqlTx, db: sqlDB, conn: conn, opts: readOnlyTxOptions}
+
+	panicked := true
+	defer func() {
+		// Make sure the transaction is closed if f panics.
+		if panicked {
+			_ = ct.Tx.Rollback()
+		}
+	}()
+	err = f(ct)
+	panicked = false
+	if err != nil {
+		_ = ct.Tx.Rollback()
+		return err
+	}
+	return ct.commit()
+}
+
+func inReadOnlyTransaction(db *gorm.DB) bool {
+	if v, ok := db.Get(readOnlyTransactionKey); ok {
+		readOnly, _ := v.(bool)
+		return readOnly
+	}
+	return false
+}
+
+// rejectWritesInReadOnlyTransaction is a callback that returns an error for
+// write operations that are executed in a read-only transaction.
+func rejectWritesInReadOnlyTransaction(db *gorm.DB) {
+	if inReadOnlyTransaction(db) {
+		_ = db.AddError(fmt.Errorf("spanner: write operations are not allowed in a read-only transaction"))
+	}
+}
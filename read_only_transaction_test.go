@@ -0,0 +1,90 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestReadOnlyTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(
+		"SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL",
+		&testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: emptySingersResultSet()},
+	)
+	opts := &ReadOnlyTransactionOptions{TimestampBound: spanner.ExactStaleness(10 * time.Second)}
+	err := ReadOnlyTransaction(db, opts, func(tx *gorm.DB) error {
+		var singers []singer
+		if err := tx.Find(&singers).Error; err != nil {
+			return err
+		}
+		return tx.Find(&singers).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("begin request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	readOnly := beginRequests[0].(*spannerpb.BeginTransactionRequest).GetOptions().GetReadOnly()
+	if readOnly == nil {
+		t.Fatal("transaction is not a read-only transaction")
+	}
+	if g, w := readOnly.GetExactStaleness().AsDuration(), 10*time.Second; g != w {
+		t.Fatalf("staleness mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("sql request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.CommitRequest{}))
+	if g, w := len(commitRequests), 0; g != w {
+		t.Fatalf("commit request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestWriteInReadOnlyTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	err := ReadOnlyTransaction(db, nil, func(tx *gorm.DB) error {
+		return tx.Create(&singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}).Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for create in a read-only transaction")
+	}
+	err = ReadOnlyTransaction(db, nil, func(tx *gorm.DB) error {
+		return tx.Exec("DELETE FROM singers WHERE TRUE").Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for exec in a read-only transaction")
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"hash"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// Cloud Spanner does not support savepoints. Savepoints are therefore emulated
+// by recording all statements and mutations in a transaction. Rolling back to
+// a savepoint rolls back the Cloud Spanner transaction, starts a new
+// transaction, and replays the statements and mutations up to the savepoint.
+// Rolling back to a savepoint fails with an Aborted error if a replayed query
+// returns different results, or if a replayed write statement affects a
+// different number of rows than in the original transaction. The transaction
+// can no longer be used if it could not be rolled back to a savepoint.
+//
+// The statements must be recorded from the start of a transaction, as rolling
+// back to a savepoint also replays the statements before the first savepoint.
+// Recording the statements makes all read/write transactions slower, and
+// savepoints must therefore be enabled with Config.EnableSavepoints.
+// Statements are not recorded if nested transactions have been disabled, and
+// in read-only transactions. Rolling back to a savepoint in a read-only
+// transaction does not undo anything and keeps the snapshot of the
+// transaction.
+
+// queryResultKey is the key of the queryResult of a query that is executed in
+// a transaction that records its statements.
+const queryResultKey = "spanner:query_result"
+
+// transactionStatement is a statement or a set of mutations that has been
+// executed in a transaction.
+type transactionStatement struct {
+	query        string
+	args         []interface{}
+	isQuery      bool
+	rowsAffected int64
+	result       *queryResult
+	mutations    []*spanner.Mutation
+}
+
+// queryResult contains a checksum of the rows that have been read from the
+// results of a query.
+type queryResult struct {
+	checksum [sha256.Size]byte
+	// rows is the number of rows that have been read.
+	rows int
+	// exhausted indicates whether all rows of the query have been read.
+	exhausted bool
+}
+
+// savepoint is a named position in the list of statements of a transaction.
+type savepoint struct {
+	name     string
+	position int
+}
+
+// SavePoint implements gorm.SavePointerDialectorInterface.
+func (dialector Dialector) SavePoint(tx *gorm.DB, name string) error {
+	ct, err := savepointTransaction(tx)
+	if err != nil {
+		return err
+	}
+	if !savepointsEnabled(tx) {
+		return fmt.Errorf("spanner: savepoints are not enabled, set EnableSavepoints in the Config of the dialector to use savepoints and nested transactions")
+	}
+	if tx.DisableNestedTransaction && !ct.readOnly() {
+		return fmt.Errorf("spanner: savepoints cannot be used if nested transactions are disabled, as the statements of the transaction are not recorded")
+	}
+	ct.savepoints = append(ct.savepoints, savepoint{name: name, position: len(ct.statements)})
+	return nil
+}
+
+// RollbackTo implements gorm.SavePointerDialectorInterface.
+func (dialector Dialector) RollbackTo(tx *gorm.DB, name string) error {
+	ct, err := savepointTransaction(tx)
+	if err != nil {
+		return err
+	}
+	return ct.rollbackTo(tx.Statement.Context, name)
+}
+
+func savepointTransaction(tx *gorm.DB) (*connTx, error) {
+	ct := transactionOf(tx)
+	if ct == nil {
+		return nil, fmt.Errorf("spanner: savepoints are not supported for transactions of type %T", tx.Statement.ConnPool)
+	}
+	if ct.err != nil {
+		return nil, ct.err
+	}
+	return ct, nil
+}
+
+// recordingTransactionOf returns the transaction that the statement is
+// executed in, or nil if the statement is not executed in a transaction that
+// records its statements.
+func recordingTransactionOf(db *gorm.DB) *connTx {
+	if db.DisableNestedTransaction || !savepointsEnabled(db) {
+		return nil
+	}
+	if tx := transactionOf(db); tx != nil && !tx.readOnly() {
+		return tx
+	}
+	return nil
+}
+
+// savepointsEnabled returns true if savepoints have been enabled in the
+// Config of the dialector of db.
+func savepointsEnabled(db *gorm.DB) bool {
+	var config *Config
+	switch dialector := db.Dialector.(type) {
+	case *Dialector:
+		config = dialector.Config
+	case Dialector:
+		config = dialector.Config
+	}
+	return config != nil && config.EnableSavepoints
+}
+
+// transactionOf returns the transaction that the statement is executed in, or
+// nil if the statement is not executed in a transaction that records its
+// statements.
+func transactionOf(db *gorm.DB) *connTx {
+	switch p := db.Statement.ConnPool.(type) {
+	case *connTx:
+		return p
+	case *gorm.PreparedStmtTX:
+		if tx, ok := p.Tx.(*connTx); ok {
+			return tx
+		}
+	}
+	return nil
+}
+
+// recordStatement is a callback that records a statement that has been
+// executed in a transaction, so it can be replayed when the transaction is
+// rolled back to a savepoint.
+func recordStatement(isQuery bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		result, _ := db.Statement.Settings.LoadAndDelete(queryResultKey)
+		if db.Error != nil || db.DryRun || db.Statement.SQL.Len() == 0 {
+			return
+		}
+		tx := recordingTransactionOf(db)
+		if tx == nil {
+			return
+		}
+		stmt := transactionStatement{
+			query:        db.Statement.SQL.String(),
+			args:         append([]interface{}{}, db.Statement.Vars...),
+			isQuery:      isQuery,
+			rowsAffected: -1,
+		}
+		if isQuery {
+			// The results of Row and Rows queries are read by the
+			// application after the statement has been executed, and are
+			// therefore not verified when the query is replayed.
+			stmt.result, _ = result.(*queryResult)
+		} else {
+			// Statements with a THEN RETURN clause are executed as queries.
+			// Only the number of returned rows is verified for these.
+			_, stmt.isQuery = db.Statement.Clauses["RETURNING"]
+			// The number of affected rows of a statement in a DML batch is not
+			// known until the batch is executed.
+			if !useBatchDML(db) {
+				stmt.rowsAffected = db.RowsAffected
+			}
+		}
+		tx.statements = append(tx.statements, stmt)
+	}
+}
+
+// query is the query callback for statements that are not executed with a
+// read-only staleness. A query in a transaction that records its statements
+// calculates a checksum of the rows that are read, so the results can be
+// verified when the query is replayed.
+func query(db *gorm.DB) {
+	if recordingTransactionOf(db) == nil {
+		callbacks.Query(db)
+		return
+	}
+	if db.Error != nil {
+		return
+	}
+	callbacks.BuildQuerySQL(db)
+	if db.DryRun || db.Error != nil {
+		return
+	}
+	rows, err := db.Statement.ConnPool.QueryContext(db.Statement.Context, db.Statement.SQL.String(), db.Statement.Vars...)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	defer func() {
+		_ = db.AddError(rows.Close())
+	}()
+	checksumRows := &checksumRows{Rows: rows, hash: sha256.New()}
+	gorm.Scan(checksumRows, db, 0)
+	db.Statement.Settings.Store(queryResultKey, checksumRows.result())
+}
+
+// checksumRows calculates a checksum of the rows that are read from a query.
+type checksumRows struct {
+	*sql.Rows
+	hash      hash.Hash
+	rows      int
+	scanned   bool
+	exhausted bool
+}
+
+func (r *checksumRows) Next() bool {
+	next := r.Rows.Next()
+	r.scanned = false
+	r.exhausted = !next
+	return next
+}
+
+func (r *checksumRows) Scan(dest ...interface{}) error {
+	if err := r.addRow(); err != nil {
+		return err
+	}
+	return r.Rows.Scan(dest...)
+}
+
+// addRow adds the current row to the checksum.
+func (r *checksumRows) addRow() error {
+	if r.scanned {
+		return nil
+	}
+	columns, err := r.Rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := r.Rows.Scan(pointers...); err != nil {
+		return err
+	}
+	for _, value := range values {
+		_, _ = fmt.Fprintf(r.hash, "%T:%v;", value, value)
+	}
+	r.rows++
+	r.scanned = true
+	return nil
+}
+
+func (r *checksumRows) result() *queryResult {
+	result := &queryResult{rows: r.rows, exhausted: r.exhausted}
+	copy(result.checksum[:], r.hash.Sum(nil))
+	return result
+}
+
+// bufferWrite buffers the given mutations in the transaction.
+func (tx *connTx) bufferWrite(db *gorm.DB, mutations []*spanner.Mutation) error {
+	if err := rawSpannerConn(tx.conn, func(conn spannerdriver.SpannerConn) error {
+		return conn.BufferWrite(mutations)
+	}); err != nil {
+		return err
+	}
+	if recordingTransactionOf(db) != nil {
+		tx.statements = append(tx.statements, transactionStatement{mutations: mutations})
+	}
+	return nil
+}
+
+func (tx *connTx) rollbackTo(ctx context.Context, name string) error {
+	index := -1
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i].name == name {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return fmt.Errorf("spanner: savepoint %s does not exist", name)
+	}
+	statements := tx.statements[:tx.savepoints[index].position]
+	tx.savepoints = tx.savepoints[:index+1]
+	// A read-only transaction has nothing to undo, and keeps reading from the
+	// same snapshot.
+	if tx.readOnly() {
+		return nil
+	}
+
+	if err := tx.Tx.Rollback(); err != nil {
+		return err
+	}
+	if err := tx.replayAll(ctx, statements); err != nil {
+		// The transaction contains a part of the replayed statements, and
+		// can no longer be used.
+		_ = tx.Tx.Rollback()
+		tx.statements, tx.savepoints = nil, nil
+		tx.err = fmt.Errorf("spanner: failed to roll back to savepoint %s: %w", name, err)
+		return tx.err
+	}
+	tx.statements = statements
+	return nil
+}
+
+// replayAll starts a new transaction and replays the given statements.
+func (tx *connTx) replayAll(ctx context.Context, statements []transactionStatement) error {
+	sqlTx, err := tx.conn.BeginTx(ctx, tx.opts)
+	if err != nil {
+		return err
+	}
+	tx.Tx = sqlTx
+	for _, stmt := range statements {
+		if err := tx.replay(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replay executes a statement or buffers a set of mutations again in a new
+// transaction.
+func (tx *connTx) replay(ctx context.Context, stmt transactionStatement) error {
+	if stmt.mutations != nil {
+		return rawSpannerConn(tx.conn, func(conn spannerdriver.SpannerConn) error {
+			return conn.BufferWrite(stmt.mutations)
+		})
+	}
+	var rowsAffected int64
+	if stmt.isQuery {
+		rows, err := tx.Tx.QueryContext(ctx, stmt.query, stmt.args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if stmt.result != nil {
+			return verifyQueryResult(rows, stmt.result)
+		}
+		for rows.Next() {
+			rowsAffected++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	} else {
+		res, err := tx.Tx.ExecContext(ctx, stmt.query, stmt.args...)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return err
+		}
+	}
+	if stmt.rowsAffected >= 0 && rowsAffected != stmt.rowsAffected {
+		return spannerdriver.ErrAbortedDueToConcurrentModification
+	}
+	return nil
+}
+
+// verifyQueryResult reads the same number of rows from the given rows as from
+// the original query, and returns an Aborted error if the rows are different.
+func verifyQueryResult(rows *sql.Rows, result *queryResult) error {
+	checksumRows := &checksumRows{Rows: rows, hash: sha256.New()}
+	for checksumRows.rows < result.rows && checksumRows.Next() {
+		if err := checksumRows.addRow(); err != nil {
+			return err
+		}
+	}
+	if result.exhausted && checksumRows.rows == result.rows {
+		checksumRows.Next()
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if *checksumRows.result() != *result {
+		return spannerdriver.ErrAbortedDueToConcurrentModification
+	}
+	return nil
+}
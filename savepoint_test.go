@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+const updateSingerSQL = "UPDATE `singers` SET `first_name`=@p1,`updated_at`=@p2 WHERE `singers`.`deleted_at` IS NULL AND `id` = @p3"
+
+func TestNestedTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupSavepointTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(updateSingerSQL, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&singer{Model: gorm.Model{ID: 1}}).Update("first_name", "First").Error; err != nil {
+			return err
+		}
+		if err := tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&singer{Model: gorm.Model{ID: 2}}).Update("first_name", "First").Error; err != nil {
+				return err
+			}
+			return errors.New("test error")
+		}); err == nil {
+			t.Fatal("missing expected error from nested transaction")
+		}
+		return tx.Model(&singer{Model: gorm.Model{ID: 3}}).Update("first_name", "First").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	// The first update is executed twice, as it is replayed when the
+	// transaction is rolled back to the savepoint.
+	if g, w := len(sqlRequests), 4; g != w {
+		t.Fatalf("sql request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	rollbackRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.RollbackRequest{}))
+	if g, w := len(rollbackRequests), 1; g != w {
+		t.Fatalf("rollback request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.CommitRequest{}))
+	if g, w := len(commitRequests), 1; g != w {
+		t.Fatalf("commit request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRollbackToSavepointWithDifferentUpdateCount(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupSavepointTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(updateSingerSQL, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&singer{Model: gorm.Model{ID: 1}}).Update("first_name", "First").Error; err != nil {
+			return err
+		}
+		_ = tx.Transaction(func(tx *gorm.DB) error {
+			// Simulate a concurrent modification of the data that was updated
+			// before the savepoint.
+			_ = server.TestSpanner.PutStatementResult(updateSingerSQL, &testutil.StatementResult{
+				Type:        testutil.StatementResultUpdateCount,
+				UpdateCount: 2,
+			})
+			return errors.New("test error")
+		})
+		return tx.Error
+	})
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRollbackToSavepointWithDifferentQueryResult(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupSavepointTestGormConnection(t)
+	defer teardown()
+
+	query := "SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: testutil.CreateSingleColumnResultSet([]int64{1, 2}, "id"),
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var singers []singer
+		if err := tx.Find(&singers).Error; err != nil {
+			return err
+		}
+		_ = tx.Transaction(func(tx *gorm.DB) error {
+			// Simulate a concurrent modification of the data that was read
+			// before the savepoint.
+			_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+				Type:      testutil.StatementResultResultSet,
+				ResultSet: testutil.CreateSingleColumnResultSet([]int64{1, 3}, "id"),
+			})
+			return errors.New("test error")
+		})
+		return tx.Error
+	})
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestSavepointWithNestedTransactionsDisabled(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupSavepointTestGormConnection(t)
+	defer teardown()
+
+	err := db.Session(&gorm.Session{DisableNestedTransaction: true}).Transaction(func(tx *gorm.DB) error {
+		return tx.SavePoint("sp1").Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for savepoint with nested transactions disabled")
+	}
+}
+
+func TestSavepointsNotEnabled(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(updateSingerSQL, &testutil.StatementResult{
+		Type:        testutil.StatementResultUpdateCount,
+		UpdateCount: 1,
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&singer{Model: gorm.Model{ID: 1}}).Update("first_name", "First").Error; err != nil {
+			return err
+		}
+		if g, w := len(transactionOf(tx).statements), 0; g != w {
+			t.Fatalf("recorded statement count mismatch\n Got: %v\nWant: %v", g, w)
+		}
+		return tx.SavePoint("sp1").Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for savepoint without EnableSavepoints")
+	}
+}
+
+func setupSavepointTestGormConnection(t *testing.T) (db *gorm.DB, server *testutil.MockedSpannerInMemTestServer, teardown func()) {
+	server, _, serverTeardown := setupMockedTestServer(t)
+	db, err := gorm.Open(New(Config{
+		DriverName:       "spanner",
+		DSN:              fmt.Sprintf("%s/projects/p/instances/i/databases/d?useplaintext=true", server.Address),
+		EnableSavepoints: true,
+	}), &gorm.Config{PrepareStmt: true})
+	if err != nil {
+		serverTeardown()
+		t.Fatal(err)
+	}
+	return db, server, serverTeardown
+}
@@ -17,16 +17,18 @@ package gorm
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"reflect"
+	"strings"
 
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
 	"gorm.io/gorm"
 	"gorm.io/gorm/callbacks"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/migrator"
 	"gorm.io/gorm/schema"
-
-	_ "github.com/googleapis/go-sql-spanner"
 )
 
 type Config struct {
@@ -41,6 +43,22 @@ type Config struct {
 	// if you are experiencing problems with the automatic batching of DDL
 	// statements when calling AutoMigrate.
 	DisableAutoMigrateBatching bool
+
+	// DefaultPriority is the priority that is used for all queries, DML
+	// statements and commits that are executed by gorm. The priority is set
+	// for the connections that are opened with DSN, and replaces any
+	// rpcPriority property in the DSN. DefaultPriority cannot be used in
+	// combination with Conn. Use the Priority scope or WithPriority to use a
+	// different priority for individual statements or transactions.
+	DefaultPriority spannerpb.RequestOptions_Priority
+
+	// EnableSavepoints turns on the emulation of savepoints, which gorm uses
+	// for nested transactions. Cloud Spanner does not support savepoints, and
+	// read/write transactions that are started by gorm therefore record all
+	// their statements and calculate a checksum of the results of all their
+	// queries when this option is enabled. SavePoint returns an error if this
+	// option is not enabled.
+	EnableSavepoints bool
 }
 
 type Dialector struct {
@@ -60,9 +78,10 @@ func (dialector Dialector) Name() string {
 }
 
 func (dialector Dialector) Initialize(db *gorm.DB) (err error) {
-	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{
+	callbacksConfig := &callbacks.Config{
 		CreateClauses: []string{"INSERT", "VALUES", "RETURNING"},
-	})
+	}
+	callbacks.RegisterDefaultCallbacks(db, callbacksConfig)
 	if dialector.DriverName == "" {
 		dialector.DriverName = "spanner"
 	}
@@ -75,45 +94,373 @@ func (dialector Dialector) Initialize(db *gorm.DB) (err error) {
 		Register("gorm:spanner:remove_primary_key_from_update", BeforeUpdate); err != nil {
 		return err
 	}
+	// Register CREATE, UPDATE and DELETE callbacks that can use mutations or
+	// Partitioned DML instead of normal DML.
+	if err := registerWriteCallbacks(db, callbacksConfig); err != nil {
+		return err
+	}
+	// Register query callbacks that can execute stale reads.
+	if err := registerQueryCallbacks(db); err != nil {
+		return err
+	}
+	// Register callbacks that translate locking clauses to statement hints.
+	if err := registerLockingCallbacks(db); err != nil {
+		return err
+	}
+	// Register callbacks that add statement hints to queries and DML statements.
+	if err := registerStatementHintCallbacks(db); err != nil {
+		return err
+	}
+	// Register callbacks that reject write operations in read-only transactions.
+	if err := registerReadOnlyTransactionCallbacks(db); err != nil {
+		return err
+	}
+	// Register callbacks that record the statements in a transaction, so the
+	// transaction can be rolled back to a savepoint.
+	if err := registerSavepointCallbacks(db); err != nil {
+		return err
+	}
+	// Register callbacks that keep a reference to the default transaction of a
+	// statement, so the commit timestamp can be retrieved.
+	if err := registerCommitTimestampCallbacks(db); err != nil {
+		return err
+	}
 
 	if dialector.Conn != nil {
+		if dialector.DefaultPriority != spannerpb.RequestOptions_PRIORITY_UNSPECIFIED {
+			return fmt.Errorf("spanner: DefaultPriority cannot be used with Conn, set the rpcPriority property in the DSN of the connection instead")
+		}
 		db.ConnPool = dialector.Conn
 	} else {
-		db.ConnPool, err = sql.Open(dialector.DriverName, dialector.DSN)
+		// Connection pools for other priorities can only be opened if the
+		// DSN is known. These pools are closed together with the connection
+		// pool of the Dialector.
+		priorities := &priorityPools{
+			driverName:      dialector.DriverName,
+			dsn:             dialector.DSN,
+			defaultPriority: dialector.DefaultPriority,
+		}
+		sqlDB, err := openDB(dialector.DriverName, dsnWithPriority(dialector.DSN, dialector.DefaultPriority), priorities)
 		if err != nil {
 			return err
 		}
+		pool := &connPool{DB: sqlDB, priorities: priorities}
+		priorities.pools = map[spannerpb.RequestOptions_Priority]*connPool{dialector.DefaultPriority: pool}
+		db.ConnPool = pool
+	}
+	// Wrap the connection pool so transactions keep a reference to the
+	// underlying Spanner connection.
+	if sqlDB, ok := db.ConnPool.(*sql.DB); ok {
+		db.ConnPool = &connPool{DB: sqlDB}
 	}
 
-	// Spanner DML does not support 'ON CONFLICT' clauses.
+	// Spanner DML does not support 'ON CONFLICT' clauses. Instead, the INSERT
+	// clause is translated to INSERT OR UPDATE / INSERT OR IGNORE if the
+	// statement contains an OnConflict clause that can be expressed that way.
 	db.ClauseBuilders[clause.OnConflict{}.Name()] = func(c clause.Clause, builder clause.Builder) {}
-	db.ClauseBuilders[clause.Returning{}.Name()] = func(c clause.Clause, builder clause.Builder) {
-		// TODO: check if we can improve this be returning only required columns.
-		builder.WriteString("THEN RETURN *")
-	}
+	db.ClauseBuilders[clause.Insert{}.Name()] = buildInsert
+	db.ClauseBuilders[clause.Returning{}.Name()] = buildReturning
+	// Commit timestamp columns are set to PENDING_COMMIT_TIMESTAMP().
+	db.ClauseBuilders[clause.Values{}.Name()] = buildValues
+	db.ClauseBuilders[clause.Set{}.Name()] = buildSet
+	// Table hints are added after the tables in the FROM clause, and join hints
+	// are added to the joins in the FROM clause.
+	db.ClauseBuilders[clause.From{}.Name()] = buildFrom
 
 	return
 }
 
+// registerWriteCallbacks replaces the default create, update and delete
+// callbacks with callbacks that use mutations, Partitioned DML or batch DML if
+// this has been enabled for the statement, and otherwise fall back to the
+// default callbacks.
+func registerWriteCallbacks(db *gorm.DB, config *callbacks.Config) error {
+	create, update, del := callbacks.Create(config), callbacks.Update(config), callbacks.Delete(config)
+	// Statements in a DML batch are buffered locally and cannot return any
+	// values, so the batch uses INSERT statements without a THEN RETURN clause.
+	createWithoutReturning := callbacks.Create(&callbacks.Config{CreateClauses: []string{"INSERT", "VALUES"}})
+	if err := db.Callback().Create().Replace("gorm:create", func(db *gorm.DB) {
+		if useMutations(db) {
+			createMutations(db)
+		} else if useBatchDML(db) {
+			createWithoutReturning(db)
+		} else {
+			executeWithCommitTimestamp(db, create)
+		}
+	}); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Replace("gorm:update", func(db *gorm.DB) {
+		if useMutations(db) {
+			updateMutations(db)
+		} else if useBatchDML(db) && isSave(db.Statement) {
+			saveInBatch(db, createWithoutReturning)
+		} else if usePartitionedDML(db) {
+			executePartitionedDML(db, update)
+		} else {
+			executeWithCommitTimestamp(db, update)
+		}
+	}); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Replace("gorm:delete", func(db *gorm.DB) {
+		if useMutations(db) {
+			deleteMutations(db)
+		} else if usePartitionedDML(db) {
+			executePartitionedDML(db, del)
+		} else {
+			executeWithCommitTimestamp(db, del)
+		}
+	})
+}
+
+// registerQueryCallbacks replaces the default query, preload and row callbacks
+// with callbacks that execute the query with a read-only staleness if this has
+// been set for the statement.
+func registerQueryCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Query().Replace("gorm:query", func(db *gorm.DB) {
+		if staleness, ok := readOnlyStaleness(db); !ok || isStalePreload(db) {
+			query(db)
+		} else if len(db.Statement.Preloads) > 0 {
+			queryWithPreloads(db, staleness)
+		} else {
+			release := executeWithStaleness(db, staleness, callbacks.Query)
+			release()
+		}
+	}); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Replace("gorm:preload", preload); err != nil {
+		return err
+	}
+	return db.Callback().Row().Replace("gorm:row", func(db *gorm.DB) {
+		if staleness, ok := readOnlyStaleness(db); ok {
+			release := executeWithStaleness(db, staleness, callbacks.RowQuery)
+			// The rows that are returned by a row query are consumed after the
+			// callback has finished. The connection can only be returned to the
+			// pool once the rows have been closed.
+			go release()
+		} else {
+			callbacks.RowQuery(db)
+		}
+	})
+}
+
+// registerLockingCallbacks registers callbacks that translate the locking
+// clause of a query to a Cloud Spanner statement hint.
+func registerLockingCallbacks(db *gorm.DB) error {
+	const name = "gorm:spanner:locking"
+	if err := db.Callback().Query().Before("gorm:query").Register(name, translateLocking); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("gorm:row").Register(name, translateLocking)
+}
+
+// registerStatementHintCallbacks registers callbacks that add the statement
+// hints of a statement before the first clause of the statement.
+func registerStatementHintCallbacks(db *gorm.DB) error {
+	const name = "gorm:spanner:statement_hints"
+	if err := db.Callback().Query().After("gorm:spanner:locking").Before("gorm:query").Register(name, applyStatementHints(clause.Select{}.Name())); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().After("gorm:spanner:locking").Before("gorm:row").Register(name, applyStatementHints(clause.Select{}.Name())); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().Before("gorm:create").Register(name, applyStatementHints(clause.Insert{}.Name())); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register(name, applyStatementHints(clause.Update{}.Name())); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register(name, applyStatementHints(clause.Delete{}.Name()))
+}
+
+// registerReadOnlyTransactionCallbacks registers callbacks that return an
+// error for write operations that are executed in a read-only transaction.
+func registerReadOnlyTransactionCallbacks(db *gorm.DB) error {
+	const name = "gorm:spanner:read_only_transaction"
+	if err := db.Callback().Create().Before("gorm:begin_transaction").Register(name, rejectWritesInReadOnlyTransaction); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:begin_transaction").Register(name, rejectWritesInReadOnlyTransaction); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:begin_transaction").Register(name, rejectWritesInReadOnlyTransaction); err != nil {
+		return err
+	}
+	return db.Callback().Raw().Before("gorm:raw").Register(name, rejectWritesInReadOnlyTransaction)
+}
+
+// registerSavepointCallbacks registers callbacks that record the statements
+// that are executed in a transaction.
+func registerSavepointCallbacks(db *gorm.DB) error {
+	const name = "gorm:spanner:record_statement"
+	if err := db.Callback().Create().After("gorm:create").Register(name, recordStatement(false)); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register(name, recordStatement(false)); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register(name, recordStatement(false)); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().After("gorm:query").Register(name, recordStatement(true)); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().After("gorm:row").Register(name, recordStatement(true)); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register(name, recordStatement(false))
+}
+
+// registerCommitTimestampCallbacks registers callbacks that save the
+// transaction of a write operation before gorm commits its default transaction.
+func registerCommitTimestampCallbacks(db *gorm.DB) error {
+	const name = "gorm:spanner:save_transaction"
+	if err := db.Callback().Create().Before("gorm:commit_or_rollback_transaction").Register(name, saveTransaction); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:commit_or_rollback_transaction").Register(name, saveTransaction); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:commit_or_rollback_transaction").Register(name, saveTransaction)
+}
+
 func BeforeUpdate(db *gorm.DB) {
 	// Omit all primary key fields from the SET clause of an UPDATE statement.
 	db.Statement.Omit(db.Statement.Schema.PrimaryFieldDBNames...)
 }
 
+// buildInsert builds the INSERT clause of a statement. The clause is rendered
+// as INSERT OR IGNORE or INSERT OR UPDATE if the statement also contains an
+// OnConflict clause.
+func buildInsert(c clause.Clause, builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		if onConflictClause, ok := stmt.Clauses[clause.OnConflict{}.Name()]; ok {
+			if onConflict, ok := onConflictClause.Expression.(clause.OnConflict); ok {
+				var columns []clause.Column
+				if values, ok := stmt.Clauses[clause.Values{}.Name()].Expression.(clause.Values); ok {
+					columns = values.Columns
+				}
+				modifier, err := insertModifier(stmt, onConflict, columns)
+				if err != nil {
+					_ = stmt.AddError(err)
+					return
+				}
+				insert, _ := c.Expression.(clause.Insert)
+				insert.Modifier = modifier
+				c.Expression = insert
+			}
+		}
+	}
+	c.Build(builder)
+}
+
+// insertModifier returns the modifier that should be added to an INSERT
+// statement with the given columns to get the same semantics as the given
+// OnConflict clause. Cloud Spanner only supports conflicts on the primary key,
+// and an INSERT OR UPDATE statement always updates all columns in the insert
+// column list with the values that were supplied for the new row. An
+// OnConflict clause without any updates is therefore translated to INSERT OR
+// IGNORE, and an OnConflict clause that updates only some of the columns
+// returns an error. The exception are has-one and has-many associations that
+// gorm saves with an OnConflict clause that only updates the foreign key
+// columns. These are inserted with INSERT OR IGNORE, which means that the
+// foreign keys of existing associated records are not updated.
+func insertModifier(stmt *gorm.Statement, onConflict clause.OnConflict, columns []clause.Column) (string, error) {
+	if onConflict.OnConstraint != "" {
+		return "", fmt.Errorf("spanner: OnConflict with OnConstraint is not supported")
+	}
+	if len(onConflict.Where.Exprs) > 0 || len(onConflict.TargetWhere.Exprs) > 0 {
+		return "", fmt.Errorf("spanner: OnConflict with a WHERE clause is not supported")
+	}
+	if len(onConflict.Columns) > 0 && !isPrimaryKey(stmt.Schema, onConflict.Columns) {
+		return "", fmt.Errorf("spanner: OnConflict only supports conflicts on the primary key of the table")
+	}
+	if onConflict.DoNothing || !onConflict.UpdateAll && len(onConflict.DoUpdates) == 0 {
+		return "OR IGNORE", nil
+	}
+	if !onConflict.UpdateAll && isAssociationSave(stmt) {
+		return "OR IGNORE", nil
+	}
+	if !onConflict.UpdateAll {
+		updated := make(map[string]bool, len(onConflict.DoUpdates))
+		for _, assignment := range onConflict.DoUpdates {
+			if column, ok := assignment.Value.(clause.Column); !ok || column.Table != excludedTableName || column.Name != assignment.Column.Name {
+				return "", fmt.Errorf("spanner: OnConflict only supports updating columns with the values of the inserted row")
+			}
+			updated[assignment.Column.Name] = true
+		}
+		inserted := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			inserted[column.Name] = true
+			if !updated[column.Name] && !isPrimaryKeyColumn(stmt.Schema, column) {
+				return "", fmt.Errorf("spanner: OnConflict must update all inserted columns, as INSERT OR UPDATE overwrites all inserted columns, but column %s is not updated", column.Name)
+			}
+		}
+		for column := range updated {
+			if !inserted[column] {
+				return "", fmt.Errorf("spanner: OnConflict can only update inserted columns, but column %s is not inserted", column)
+			}
+		}
+	}
+	return "OR UPDATE", nil
+}
+
+// savedAssociationsKey is the setting that gorm uses to keep track of the
+// associations that it has saved. gorm copies the setting to the statements
+// that save associations.
+const savedAssociationsKey = "gorm:saved_association_map"
+
+// isAssociationSave returns true if the statement saves the associations of
+// another record.
+func isAssociationSave(stmt *gorm.Statement) bool {
+	_, ok := stmt.Settings.Load(savedAssociationsKey)
+	return ok
+}
+
+// excludedTableName is the name of the pseudo table that clause.AssignmentColumns
+// uses to reference the values of the row that was attempted to be inserted.
+const excludedTableName = "excluded"
+
+func isPrimaryKey(s *schema.Schema, columns []clause.Column) bool {
+	if s == nil || len(columns) != len(s.PrimaryFieldDBNames) {
+		return false
+	}
+	for _, column := range columns {
+		if !isPrimaryKeyColumn(s, column) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrimaryKeyColumn(s *schema.Schema, column clause.Column) bool {
+	if s == nil {
+		return false
+	}
+	field := s.LookUpField(column.Name)
+	return field != nil && field.PrimaryKey
+}
+
 func (dialector Dialector) DefaultValueOf(field *schema.Field) clause.Expression {
 	return clause.Expr{SQL: "NULL"}
 }
 
 func (dialector Dialector) Migrator(db *gorm.DB) gorm.Migrator {
-	var conn *sql.Conn
-	if c, ok := db.ConnPool.(*sql.Conn); ok && c != nil {
-		conn = c
-	} else {
-		sqlDB, _ := db.DB()
-		conn, _ = sqlDB.Conn(context.Background())
+	// The migrator uses a dedicated connection, as DDL batches are started
+	// and run on a connection.
+	conn, ok := db.ConnPool.(*sql.Conn)
+	if !ok || conn == nil {
+		var err error
+		if conn, err = dedicatedConn(db); err != nil {
+			_ = db.AddError(err)
+		}
+	}
+	if conn != nil {
+		db.ConnPool = conn
+		db.Statement.ConnPool = conn
 	}
-	db.ConnPool = conn
-	db.Statement.ConnPool = conn
 	return spannerMigrator{
 		Migrator: migrator.Migrator{
 			Config: migrator.Config{
@@ -126,7 +473,29 @@ func (dialector Dialector) Migrator(db *gorm.DB) gorm.Migrator {
 	}
 }
 
+// dedicatedConn returns a new connection from the connection pool of db.
+func dedicatedConn(db *gorm.DB) (*sql.Conn, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("spanner: failed to get a connection for the migrator: %w", err)
+	}
+	return sqlDB.Conn(context.Background())
+}
+
 func (dialector Dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	// The Cloud Spanner database/sql driver calls Value on a nil pointer to a
+	// type with a value receiver, such as *spanner.NullNumeric, which panics.
+	// gorm adds v to the variables of the statement before calling BindVarTo.
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() && len(stmt.Vars) > 0 {
+		if _, ok := v.(driver.Valuer); ok {
+			stmt.Vars[len(stmt.Vars)-1] = nil
+		}
+	}
+	// The Cloud Spanner database/sql driver does not support float32 values.
+	// These are bound as FLOAT64 values instead.
+	if isFloat32Type(reflect.TypeOf(v)) && len(stmt.Vars) > 0 {
+		stmt.Vars[len(stmt.Vars)-1] = float64Value(v)
+	}
 	writer.WriteByte('?')
 }
 
@@ -179,10 +548,63 @@ func (dialector Dialector) QuoteTo(writer clause.Writer, str string) {
 }
 
 func (dialector Dialector) Explain(sql string, vars ...interface{}) string {
-	return logger.ExplainSQL(sql, nil, `'`, vars...)
+	// Dates, JSON values and arrays are rendered as DATE, JSON and ARRAY
+	// literals. All other values are rendered by gorm. Question marks in
+	// quoted literals and identifiers are not placeholders.
+	var (
+		idx     int
+		quote   byte
+		escaped bool
+		newSQL  strings.Builder
+	)
+	for _, c := range []byte(sql) {
+		switch {
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && idx < len(vars):
+			newSQL.WriteString(explainValue(vars[idx]))
+			idx++
+			continue
+		}
+		newSQL.WriteByte(c)
+	}
+	return newSQL.String()
+}
+
+// explainValue returns the literal that is used for v in Explain.
+func explainValue(v interface{}) string {
+	if literal, ok := valueLiteral(v); ok {
+		return literal
+	}
+	return logger.ExplainSQL("?", nil, `'`, v)
 }
 
 func (dialector Dialector) DataTypeOf(field *schema.Field) string {
+	if _, ok := field.TagSettings["TYPE"]; !ok {
+		if dataType, ok := registeredDataType(field); ok {
+			return dataType
+		}
+		if dataType, ok := dialector.serializerDataType(field); ok {
+			return dataType
+		}
+	}
+	if strings.EqualFold(string(field.DataType), "JSON") {
+		return "JSON"
+	}
+	if strings.EqualFold(string(field.DataType), "NUMERIC") {
+		return "NUMERIC"
+	}
+	if strings.EqualFold(string(field.DataType), "ARRAY") && isSliceField(field) {
+		return dialector.arrayDataType(field)
+	}
 	switch field.DataType {
 	case schema.Bool:
 		return "BOOL"
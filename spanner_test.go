@@ -0,0 +1,162 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestOnConflict(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	for _, test := range []struct {
+		name       string
+		onConflict clause.OnConflict
+		wantSQL    string
+		wantErr    bool
+	}{
+		{
+			name:       "DoNothing",
+			onConflict: clause.OnConflict{DoNothing: true},
+			wantSQL:    "INSERT OR IGNORE INTO `singers` (`created_at`,`updated_at`,`deleted_at`,`first_name`,`last_name`,`full_name`,`active`,`id`) VALUES (?,?,?,?,?,?,?,?) THEN RETURN *",
+		},
+		{
+			name:       "UpdateAll",
+			onConflict: clause.OnConflict{UpdateAll: true},
+			wantSQL:    "INSERT OR UPDATE INTO `singers` (`created_at`,`updated_at`,`deleted_at`,`first_name`,`last_name`,`full_name`,`active`,`id`) VALUES (?,?,?,?,?,?,?,?) THEN RETURN *",
+		},
+		{
+			name:       "Empty",
+			onConflict: clause.OnConflict{},
+			wantSQL:    "INSERT OR IGNORE INTO `singers` (`created_at`,`updated_at`,`deleted_at`,`first_name`,`last_name`,`full_name`,`active`,`id`) VALUES (?,?,?,?,?,?,?,?) THEN RETURN *",
+		},
+		{
+			name: "DoUpdates",
+			onConflict: clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"created_at", "updated_at", "deleted_at", "first_name", "last_name", "full_name", "active"}),
+			},
+			wantSQL: "INSERT OR UPDATE INTO `singers` (`created_at`,`updated_at`,`deleted_at`,`first_name`,`last_name`,`full_name`,`active`,`id`) VALUES (?,?,?,?,?,?,?,?) THEN RETURN *",
+		},
+		{
+			name: "DoUpdatesSubset",
+			onConflict: clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"first_name", "last_name"}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "DoUpdatesNotInserted",
+			onConflict: clause.OnConflict{
+				DoUpdates: clause.AssignmentColumns([]string{"created_at", "updated_at", "deleted_at", "first_name", "last_name", "full_name", "active", "unknown"}),
+			},
+			wantErr: true,
+		},
+		{
+			name:       "ConflictTarget",
+			onConflict: clause.OnConflict{Columns: []clause.Column{{Name: "first_name"}}, DoNothing: true},
+			wantErr:    true,
+		},
+		{
+			name:       "OnConstraint",
+			onConflict: clause.OnConflict{OnConstraint: "idx_singers_name", DoNothing: true},
+			wantErr:    true,
+		},
+		{
+			name: "Where",
+			onConflict: clause.OnConflict{
+				Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "active", Value: true}}},
+				UpdateAll: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "DoUpdatesWithValue",
+			onConflict: clause.OnConflict{
+				DoUpdates: clause.Assignments(map[string]interface{}{"active": false}),
+			},
+			wantErr: true,
+		},
+	} {
+		s := singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}
+		tx := db.Session(&gorm.Session{DryRun: true}).Clauses(test.onConflict).Create(&s)
+		if test.wantErr {
+			if tx.Error == nil {
+				t.Fatalf("%s: missing expected error", test.name)
+			}
+			continue
+		}
+		if tx.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, tx.Error)
+		}
+		if g, w := tx.Statement.SQL.String(), test.wantSQL; g != w {
+			t.Fatalf("%s: sql mismatch\n Got: %s\nWant: %s", test.name, g, w)
+		}
+	}
+}
+
+type songWriter struct {
+	ID    int64 `gorm:"primarykey;autoIncrement:false"`
+	Name  string
+	Songs []song
+}
+
+type song struct {
+	ID           int64 `gorm:"primarykey;autoIncrement:false"`
+	Title        string
+	SongWriterID int64
+}
+
+func TestCreateWithHasManyAssociation(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	for _, sql := range []string{
+		"INSERT INTO `song_writers` (`id`,`name`) VALUES (@p1,@p2)",
+		"INSERT OR IGNORE INTO `songs` (`id`,`title`,`song_writer_id`) VALUES (@p1,@p2,@p3),(@p4,@p5,@p6)",
+	} {
+		_ = server.TestSpanner.PutStatementResult(sql, &testutil.StatementResult{
+			Type:        testutil.StatementResultUpdateCount,
+			UpdateCount: 1,
+		})
+	}
+	w := songWriter{ID: 1, Name: "Name", Songs: []song{{ID: 1, Title: "First"}, {ID: 2, Title: "Second"}}}
+	if err := db.Create(&w).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range w.Songs {
+		if g, w := s.SongWriterID, w.ID; g != w {
+			t.Fatalf("song writer id mismatch\n Got: %v\nWant: %v", g, w)
+		}
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("ExecuteSqlRequests count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+const (
+	readOnlyStalenessKey = "spanner:read_only_staleness"
+	// stalePreloadKey marks the queries that preload the associations of a
+	// stale query. These queries are executed in the read-only transaction of
+	// the stale query.
+	stalePreloadKey = "spanner:stale_preload"
+	// preloadedKey marks a stale query whose associations have already been
+	// preloaded.
+	preloadedKey = "spanner:preloaded"
+)
+
+// ExactStaleness returns a scope that executes queries at a timestamp that is
+// exactly the given duration in the past. Stale reads can be served by the
+// nearest replica and do not take any locks.
+// See https://cloud.google.com/spanner/docs/timestamp-bounds for more
+// information on timestamp bounds.
+//
+// Stale reads can only be used for queries that are not executed in a
+// transaction. A query with Preload and the queries that preload its
+// associations are executed in one read-only transaction, so all records are
+// read from the same snapshot.
+//
+// Example:
+//
+//	db.Scopes(spannergorm.ExactStaleness(15*time.Second)).Find(&singers)
+func ExactStaleness(d time.Duration) func(db *gorm.DB) *gorm.DB {
+	return withReadOnlyStaleness(readOnlyStalenessSetting{bound: spanner.ExactStaleness(d)})
+}
+
+// MaxStaleness returns a scope that executes queries at a timestamp that is at
+// most the given duration in the past. Cloud Spanner chooses the most recent
+// timestamp that can be served without blocking. MaxStaleness cannot be used
+// for queries with Preload.
+func MaxStaleness(d time.Duration) func(db *gorm.DB) *gorm.DB {
+	return withReadOnlyStaleness(readOnlyStalenessSetting{bound: spanner.MaxStaleness(d), singleUse: true})
+}
+
+// ReadTimestamp returns a scope that executes queries at the given timestamp.
+func ReadTimestamp(t time.Time) func(db *gorm.DB) *gorm.DB {
+	return withReadOnlyStaleness(readOnlyStalenessSetting{bound: spanner.ReadTimestamp(t)})
+}
+
+// MinReadTimestamp returns a scope that executes queries at a timestamp that
+// is at least the given timestamp. Cloud Spanner chooses the most recent
+// timestamp that can be served without blocking. MinReadTimestamp cannot be
+// used for queries with Preload.
+func MinReadTimestamp(t time.Time) func(db *gorm.DB) *gorm.DB {
+	return withReadOnlyStaleness(readOnlyStalenessSetting{bound: spanner.MinReadTimestamp(t), singleUse: true})
+}
+
+// readOnlyStalenessSetting is the read-only staleness of a statement.
+type readOnlyStalenessSetting struct {
+	bound spanner.TimestampBound
+	// singleUse is true if the timestamp bound can only be used for a single
+	// query, and not for a read-only transaction.
+	singleUse bool
+}
+
+func withReadOnlyStaleness(staleness readOnlyStalenessSetting) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(readOnlyStalenessKey, staleness)
+	}
+}
+
+func readOnlyStaleness(db *gorm.DB) (readOnlyStalenessSetting, bool) {
+	if v, ok := db.Get(readOnlyStalenessKey); ok {
+		staleness, ok := v.(readOnlyStalenessSetting)
+		return staleness, ok
+	}
+	return readOnlyStalenessSetting{}, false
+}
+
+// executeWithStaleness executes the query on a single connection that uses the
+// given staleness for queries in autocommit mode. The returned function must
+// be called to return the connection to the pool once the query has finished.
+func executeWithStaleness(db *gorm.DB, staleness readOnlyStalenessSetting, exec func(db *gorm.DB)) (release func()) {
+	if db.Error != nil {
+		return func() {}
+	}
+	if inTransaction(db) {
+		_ = db.AddError(fmt.Errorf("spanner: stale reads cannot be used in a transaction"))
+		return func() {}
+	}
+	conn, release, err := sqlConn(db.Statement.Context, db.Statement.ConnPool)
+	if err != nil {
+		_ = db.AddError(err)
+		return func() {}
+	}
+	if err := rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+		return conn.SetReadOnlyStaleness(staleness.bound)
+	}); err != nil {
+		_ = db.AddError(err)
+		return release
+	}
+	// The staleness is only used when the query is started, and can therefore
+	// be reset before the results have been consumed.
+	defer func() {
+		_ = db.AddError(rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+			return conn.SetReadOnlyStaleness(spanner.StrongRead())
+		}))
+	}()
+
+	pool := db.Statement.ConnPool
+	db.Statement.ConnPool = conn
+	defer func() {
+		db.Statement.ConnPool = pool
+	}()
+	exec(db)
+	return release
+}
+
+// queryWithPreloads executes a stale query and the queries that preload its
+// associations in one read-only transaction, so all records are read from the
+// same snapshot.
+func queryWithPreloads(db *gorm.DB, staleness readOnlyStalenessSetting) {
+	if db.Error != nil {
+		return
+	}
+	if staleness.singleUse {
+		_ = db.AddError(fmt.Errorf("spanner: MaxStaleness and MinReadTimestamp cannot be used with Preload, use ExactStaleness or ReadTimestamp instead"))
+		return
+	}
+	if inTransaction(db) {
+		_ = db.AddError(fmt.Errorf("spanner: stale reads cannot be used in a transaction"))
+		return
+	}
+	pool := db.Statement.ConnPool
+	defer func() {
+		db.Statement.ConnPool = pool
+		db.Statement.Settings.Delete(stalePreloadKey)
+	}()
+	err := runReadOnlyTransaction(db, staleness.bound, func(ct *connTx) error {
+		db.Statement.ConnPool = ct
+		// The setting is copied to the statements that preload the
+		// associations.
+		db.Statement.Settings.Store(stalePreloadKey, true)
+		callbacks.Query(db)
+		callbacks.Preload(db)
+		return nil
+	})
+	if err != nil {
+		_ = db.AddError(err)
+	}
+	db.Statement.Settings.Store(preloadedKey, true)
+}
+
+func isStalePreload(db *gorm.DB) bool {
+	_, ok := db.Statement.Settings.Load(stalePreloadKey)
+	return ok
+}
+
+// preload is the preload callback of the dialect. The associations of stale
+// queries are preloaded by queryWithPreloads.
+func preload(db *gorm.DB) {
+	if _, ok := db.Statement.Settings.LoadAndDelete(preloadedKey); ok {
+		return
+	}
+	callbacks.Preload(db)
+}
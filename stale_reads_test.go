@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"gorm.io/gorm"
+
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestQueryWithExactStaleness(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	query := "SELECT * FROM `singers` WHERE `singers`.`deleted_at` IS NULL"
+	_ = server.TestSpanner.PutStatementResult(query, &testutil.StatementResult{
+		Type:      testutil.StatementResultResultSet,
+		ResultSet: emptySingersResultSet(),
+	})
+	var singers []singer
+	if err := db.Scopes(ExactStaleness(10 * time.Second)).Find(&singers).Error; err != nil {
+		t.Fatal(err)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("sql request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	req := sqlRequests[0].(*spannerpb.ExecuteSqlRequest)
+	if g, w := req.Sql, query; g != w {
+		t.Fatalf("sql mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	readOnly := req.Transaction.GetSingleUse().GetReadOnly()
+	if readOnly == nil {
+		t.Fatal("query was not executed in a single-use read-only transaction")
+	}
+	if g, w := readOnly.GetExactStaleness().AsDuration(), 10*time.Second; g != w {
+		t.Fatalf("staleness mismatch\n Got: %v\nWant: %v", g, w)
+	}
+
+	// The staleness should only be used for the statement that it was set on.
+	if err := db.Find(&singers).Error; err != nil {
+		t.Fatal(err)
+	}
+	requests = drainRequestsFromServer(server.TestSpanner)
+	sqlRequests = requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 1; g != w {
+		t.Fatalf("sql request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if readOnly := sqlRequests[0].(*spannerpb.ExecuteSqlRequest).Transaction.GetSingleUse().GetReadOnly(); readOnly.GetExactStaleness() != nil {
+		t.Fatalf("unexpected staleness for query: %v", readOnly)
+	}
+}
+
+func TestQueryWithExactStalenessAndPreload(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	_ = server.TestSpanner.PutStatementResult(
+		"SELECT * FROM `albums` WHERE `albums`.`deleted_at` IS NULL",
+		&testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: testutil.CreateTwoColumnResultSet([][2]int64{{1, 2}}, [2]string{"id", "singer_id"})},
+	)
+	_ = server.TestSpanner.PutStatementResult(
+		"SELECT * FROM `singers` WHERE `singers`.`id` = @p1 AND `singers`.`deleted_at` IS NULL",
+		&testutil.StatementResult{Type: testutil.StatementResultResultSet, ResultSet: testutil.CreateSingleColumnResultSet([]int64{2}, "id")},
+	)
+	var albums []album
+	if err := db.Scopes(ExactStaleness(10 * time.Second)).Preload("Singer").Find(&albums).Error; err != nil {
+		t.Fatal(err)
+	}
+	if g, w := len(albums), 1; g != w {
+		t.Fatalf("album count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if albums[0].Singer == nil {
+		t.Fatal("singer has not been preloaded")
+	}
+
+	// The query and the preload must use the same read-only transaction.
+	requests := drainRequestsFromServer(server.TestSpanner)
+	beginRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.BeginTransactionRequest{}))
+	if g, w := len(beginRequests), 1; g != w {
+		t.Fatalf("begin request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	readOnly := beginRequests[0].(*spannerpb.BeginTransactionRequest).GetOptions().GetReadOnly()
+	if g, w := readOnly.GetExactStaleness().AsDuration(), 10*time.Second; g != w {
+		t.Fatalf("staleness mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	sqlRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.ExecuteSqlRequest{}))
+	if g, w := len(sqlRequests), 2; g != w {
+		t.Fatalf("sql request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	for _, req := range sqlRequests {
+		if req.(*spannerpb.ExecuteSqlRequest).Transaction.GetId() == nil {
+			t.Fatalf("query was not executed in the read-only transaction: %v", req)
+		}
+	}
+
+	// MaxStaleness can only be used for single queries.
+	if err := db.Scopes(MaxStaleness(10 * time.Second)).Preload("Singer").Find(&albums).Error; err == nil {
+		t.Fatal("missing expected error for MaxStaleness with Preload")
+	}
+}
+
+func TestStaleReadInTransaction(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var singers []singer
+		return tx.Scopes(MaxStaleness(10 * time.Second)).Find(&singers).Error
+	})
+	if err == nil {
+		t.Fatal("missing expected error for stale read in a transaction")
+	}
+}
+
+func emptySingersResultSet() *spannerpb.ResultSet {
+	return &spannerpb.ResultSet{
+		Metadata: &spannerpb.ResultSetMetadata{
+			RowType: &spannerpb.StructType{
+				Fields: []*spannerpb.StructType_Field{
+					{Name: "id", Type: &spannerpb.Type{Code: spannerpb.TypeCode_INT64}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 Google LLC.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"google.golang.org/grpc/codes"
+	"gorm.io/gorm"
+)
+
+const (
+	minRetryDelay = 20 * time.Millisecond
+	maxRetryDelay = 32 * time.Second
+)
+
+// RunTransactionOptions contains the options for RunTransaction.
+type RunTransactionOptions struct {
+	// MaxAttempts is the maximum number of times that the transaction is
+	// executed. The default is to retry the transaction until it succeeds,
+	// returns an error other than Aborted, or the context is done.
+	MaxAttempts int
+	// OnRetry is called before the transaction is retried with the number of
+	// the attempt that failed and the error that caused the retry.
+	OnRetry func(attempt int, err error)
+	// TxOptions are the options that are used to start the transaction.
+	TxOptions *sql.TxOptions
+}
+
+// RunTransaction executes fc in a read/write transaction. Cloud Spanner can
+// abort a read/write transaction if it conflicts with other transactions.
+// RunTransaction then retries the transaction by executing fc again in a new
+// transaction, in the same way as spanner.Client.ReadWriteTransaction.
+//
+// fc can be executed multiple times, and any gorm hooks are invoked once for
+// each attempt. Only the changes of the last attempt are committed. fc should
+// therefore not have any side effects outside the transaction, and should
+// create or reload the records that it writes inside the function, as records
+// that are modified in a failed attempt keep the values from that attempt.
+//
+// Example:
+//
+//	err := spannergorm.RunTransaction(ctx, db, func(tx *gorm.DB) error {
+//		var singer Singer
+//		if err := tx.First(&singer, 1).Error; err != nil {
+//			return err
+//		}
+//		return tx.Model(&singer).Update("active", true).Error
+//	})
+func RunTransaction(ctx context.Context, db *gorm.DB, fc func(tx *gorm.DB) error, opts ...*RunTransactionOptions) error {
+	if inTransaction(db) {
+		return fmt.Errorf("spanner: RunTransaction cannot be called in a transaction")
+	}
+	options := &RunTransactionOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+	db = db.WithContext(ctx)
+	for attempt := 1; ; attempt++ {
+		err := runTransactionAttempt(ctx, db, fc, options.TxOptions)
+		if err == nil || spanner.ErrCode(err) != codes.Aborted {
+			return err
+		}
+		if options.MaxAttempts > 0 && attempt >= options.MaxAttempts {
+			return err
+		}
+		if options.OnRetry != nil {
+			options.OnRetry(attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(err, attempt)):
+		}
+	}
+}
+
+// runTransactionAttempt executes fc once in a read/write transaction. The
+// transaction does not retry aborted transactions internally, as the retry is
+// handled by RunTransaction.
+func runTransactionAttempt(ctx context.Context, db *gorm.DB, fc func(tx *gorm.DB) error, txOpts *sql.TxOptions) error {
+	// The *sql.DB is not available if db uses a single connection.
+	sqlDB, _ := db.DB()
+	conn, release, err := sqlConn(ctx, db.Statement.ConnPool)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Restore the retry setting of the connection afterwards, as it can have
+	// been disabled in the DSN.
+	var retryAbortsInternally bool
+	if err := rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+		retryAbortsInternally = conn.RetryAbortsInternally()
+		return conn.SetRetryAbortsInternally(false)
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		_ = rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+			return conn.SetRetryAbortsInternally(retryAbortsInternally)
+		})
+	}()
+
+	sqlTx, err := conn.BeginTx(ctx, txOpts)
+	if err != nil {
+		return err
+	}
+	tx := db.Session(&gorm.Session{Context: ctx})
+	ct := &connTx{Tx: sqlTx, db: sqlDB, conn: conn, opts: txOpts}
+	tx.Statement.ConnPool = ct
+
+	panicked := true
+	defer func() {
+		// Make sure the transaction is closed if fc panics.
+		if panicked {
+			_ = ct.Tx.Rollback()
+		}
+	}()
+	err = fc(tx)
+	panicked = false
+	if err != nil {
+		_ = ct.Tx.Rollback()
+		return err
+	}
+	return ct.commit()
+}
+
+// retryDelay returns the time to wait before retrying an aborted transaction.
+// The delay that is returned by Cloud Spanner is used if there is one, and
+// otherwise an exponential backoff with jitter.
+func retryDelay(err error, attempt int) time.Duration {
+	if delay, ok := spanner.ExtractRetryDelay(err); ok {
+		return delay
+	}
+	delay := maxRetryDelay
+	if attempt < 16 {
+		delay = minRetryDelay << (attempt - 1)
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gorm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	spannerdriver "github.com/googleapis/go-sql-spanner"
+	"github.com/googleapis/go-sql-spanner/testutil"
+)
+
+func TestRunTransactionRetriesAborted(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted")},
+	})
+	attempts, retries := 0, 0
+	err := RunTransaction(context.Background(), db, func(tx *gorm.DB) error {
+		attempts++
+		s := singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}
+		return tx.Scopes(WithMutations).Create(&s).Error
+	}, &RunTransactionOptions{OnRetry: func(attempt int, err error) {
+		retries++
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := attempts, 2; g != w {
+		t.Fatalf("attempt count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := retries, 1; g != w {
+		t.Fatalf("retry count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	requests := drainRequestsFromServer(server.TestSpanner)
+	commitRequests := requestsOfType(requests, reflect.TypeOf(&spannerpb.CommitRequest{}))
+	if g, w := len(commitRequests), 2; g != w {
+		t.Fatalf("commit request count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRunTransactionMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	db, server, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	server.TestSpanner.PutExecutionTime(testutil.MethodCommitTransaction, testutil.SimulatedExecutionTime{
+		Errors: []error{status.Error(codes.Aborted, "Aborted"), status.Error(codes.Aborted, "Aborted")},
+	})
+	attempts := 0
+	err := RunTransaction(context.Background(), db, func(tx *gorm.DB) error {
+		attempts++
+		s := singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}
+		return tx.Scopes(WithMutations).Create(&s).Error
+	}, &RunTransactionOptions{MaxAttempts: 2})
+	if g, w := spanner.ErrCode(err), codes.Aborted; g != w {
+		t.Fatalf("error code mismatch\n Got: %v\nWant: %v", g, w)
+	}
+	if g, w := attempts, 2; g != w {
+		t.Fatalf("attempt count mismatch\n Got: %v\nWant: %v", g, w)
+	}
+}
+
+func TestRunTransactionRestoresRetryAbortsInternally(t *testing.T) {
+	t.Parallel()
+
+	db, _, teardown := setupTestGormConnection(t)
+	defer teardown()
+
+	// RunTransaction disables the internal retries of the connection while it
+	// executes the transaction. The connection pool also resets the retry
+	// setting of a connection when it is returned to the pool, so the test uses
+	// one connection for the whole test.
+	if err := db.Connection(func(db *gorm.DB) error {
+		if err := RunTransaction(context.Background(), db, func(tx *gorm.DB) error {
+			s := singer{Model: gorm.Model{ID: 1}, FirstName: "First", LastName: "Last"}
+			return tx.Scopes(WithMutations).Create(&s).Error
+		}); err != nil {
+			return err
+		}
+		conn, release, err := sqlConn(context.Background(), db.Statement.ConnPool)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return rawSpannerConn(conn, func(conn spannerdriver.SpannerConn) error {
+			if g, w := conn.RetryAbortsInternally(), true; g != w {
+				t.Fatalf("retryAbortsInternally mismatch\n Got: %v\nWant: %v", g, w)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}